@@ -1,21 +1,56 @@
 package main
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/fedgolang/go_final_project/internal/config"
 	"github.com/fedgolang/go_final_project/internal/handlers"
+	nd "github.com/fedgolang/go_final_project/internal/lib/nextdate"
 	"github.com/fedgolang/go_final_project/internal/storage"
 	"github.com/go-chi/chi"
 
 	_ "modernc.org/sqlite"
+
+	// Встраиваем базу часовых поясов, в контейнере её может не быть
+	_ "time/tzdata"
 )
 
 func main() {
 	r := chi.NewRouter()
 	cfg := config.Load()
 
+	// Если передали праздники, подгрузим их для расчёта рабочих дней
+	if cfg.HolidaysPath != "" {
+		cal, err := nd.LoadCalendar(cfg.HolidaysPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		nd.SetCalendar(cal)
+	}
+
+	// Скрипты правил x необязательны, папки по умолчанию может и не быть
+	if err := nd.LoadScripts(cfg.RulesDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+
+	// Политика для несуществующих дней, если задана, действует на все правила без monthend
+	if err := nd.SetMonthEnd(cfg.MonthEnd); err != nil {
+		log.Fatal(err)
+	}
+
+	// Если задан часовой пояс, "сегодня" по умолчанию считаем в нём
+	if cfg.TimeZone != "" {
+		loc, err := time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers.DefaultLocation = loc
+	}
+
 	// Открываем коннект к БД
 	s, db := storage.NewScheduler(cfg.DBPath)
 	defer db.Close() // Закроем коннект по окончанию работы
@@ -29,9 +64,18 @@ func main() {
 	// Хендлер для вычисления следующей даты
 	r.Get("/api/nextdate", handlers.NextDateHand)
 
+	// Хендлер для предпросмотра ближайших дат по правилу
+	r.Get("/api/occurrences", handlers.AuthMiddleware(handlers.Occurrences))
+
+	// Хендлер для описания правила повторения
+	r.Get("/api/repeat/describe", handlers.DescribeRepeat)
+
 	// Хендлер для вывода ближайших тасок
 	r.Get("/api/tasks", handlers.AuthMiddleware(handlers.GetTasks(s)))
 
+	// Хендлер для календаря со всеми повторениями тасок за период
+	r.Get("/api/calendar", handlers.AuthMiddleware(handlers.Calendar(s)))
+
 	// Хендлер для вывода таски по ID
 	r.Get("/api/task", handlers.AuthMiddleware(handlers.GetDataForEdit(s)))
 
@@ -41,6 +85,21 @@ func main() {
 	// Хендлер для выполнения таски
 	r.Post("/api/task/done", handlers.AuthMiddleware(handlers.TaskDone(s)))
 
+	// Хендлер для пропуска одного повторения таски
+	r.Post("/api/task/skip", handlers.AuthMiddleware(handlers.SkipTask(s)))
+
+	// Хендлер для добавления паузы повторений, например отпуска
+	r.Post("/api/pause", handlers.AuthMiddleware(handlers.PostPause(s)))
+
+	// Хендлер для вывода всех пауз
+	r.Get("/api/pauses", handlers.AuthMiddleware(handlers.GetPauses(s)))
+
+	// Хендлер для удаления паузы
+	r.Delete("/api/pause", handlers.AuthMiddleware(handlers.DeletePause(s)))
+
+	// Хендлер для сдвига задач из паузы, с dry_run только предпросмотр
+	r.Post("/api/pause/shift", handlers.AuthMiddleware(handlers.ShiftPause(s)))
+
 	// Хендлер для удаления таски
 	r.Delete("/api/task", handlers.AuthMiddleware(handlers.DeleteTask(s)))
 
@@ -0,0 +1,99 @@
+//go:build js && wasm
+
+// Пакет nextdate, собранный в WebAssembly, чтобы форма задачи проверяла правило без запросов к серверу.
+// Сборка: GOOS=js GOARCH=wasm go build -o web/nextdate.wasm ./cmd/wasm
+// Рядом в web/js нужен wasm_exec.js из той же версии Go, что и сборка.
+package main
+
+import (
+	"syscall/js"
+	"time"
+
+	nd "github.com/fedgolang/go_final_project/internal/lib/nextdate"
+)
+
+func main() {
+	register()
+
+	// Функции вызываются из JavaScript, пока открыта страница, поэтому не выходим
+	select {}
+}
+
+// register выставляет функции в объект nextdate: nextdate.next, nextdate.validate и nextdate.describe.
+// Объект уже создан web/js/nextdate.js, функции добавляются к нему, без него создаётся новый.
+// Праздников и скриптов правил x в браузере нет, такие правила считаются по умолчанию или не разбираются.
+func register() {
+	namespace := js.Global().Get("nextdate")
+	if namespace.Type() != js.TypeObject {
+		namespace = js.Global().Get("Object").New()
+		js.Global().Set("nextdate", namespace)
+	}
+	namespace.Set("next", js.FuncOf(nextDate))
+	namespace.Set("validate", js.FuncOf(validate))
+	namespace.Set("describe", js.FuncOf(describe))
+}
+
+// next(now, date, repeat) то же, что /api/nextdate: {date: "20240126"} или {error: "..."}
+func nextDate(this js.Value, args []js.Value) any {
+	values, ok := stringArgs(args, 3)
+	if !ok {
+		return failure("next принимает now, date и repeat строками")
+	}
+
+	now, err := time.ParseInLocation("20060102", values[0], time.Local)
+	if err != nil {
+		return failure("Неверный формат даты")
+	}
+	next, err := nd.NextDate(now, values[1], values[2])
+	if err != nil {
+		return failure(err.Error())
+	}
+	return map[string]any{"date": next}
+}
+
+// validate(repeat) разбирает правило: {repeat: "<правило в каноническом виде>"} или {error: "..."}
+func validate(this js.Value, args []js.Value) any {
+	values, ok := stringArgs(args, 1)
+	if !ok {
+		return failure("validate принимает repeat строкой")
+	}
+
+	rule, err := nd.ParseCached(values[0])
+	if err != nil {
+		return failure(err.Error())
+	}
+	return map[string]any{"repeat": rule.String()}
+}
+
+// describe(repeat, lang) то же, что /api/repeat/describe без даты: {description: "..."} или {error: "..."}
+func describe(this js.Value, args []js.Value) any {
+	values, ok := stringArgs(args, 2)
+	if !ok {
+		return failure("describe принимает repeat и lang строками")
+	}
+
+	description, err := nd.Describe(values[0], values[1])
+	if err != nil {
+		return failure(err.Error())
+	}
+	return map[string]any{"description": description}
+}
+
+// stringArgs проверяет, что передано ровно n строк
+func stringArgs(args []js.Value, n int) ([]string, bool) {
+	if len(args) != n {
+		return nil, false
+	}
+	values := make([]string, 0, n)
+	for _, arg := range args {
+		if arg.Type() != js.TypeString {
+			return nil, false
+		}
+		values = append(values, arg.String())
+	}
+	return values, true
+}
+
+func failure(msg string) map[string]any {
+	return map[string]any{"error": msg}
+}
@@ -0,0 +1,114 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"syscall/js"
+
+	nd "github.com/fedgolang/go_final_project/internal/lib/nextdate"
+)
+
+// Правильные правила из разных веток разбора
+var valid = []string{
+	"d 1", "d 7", "w 1,3,5", "m 1,-1", "m 31 1,3", "h 4", "y", "y 1.4,1.10", "y w1,27 1",
+	"mw 1:1,-1:5", "d 3 until 20240301", "d 2 count 3", "m 31 monthend clamp", "w 1 | m -1",
+	"RRULE:FREQ=MONTHLY;BYDAY=2TU", "0 9 * * 1-5", "sr", "hebrew 15 nisan", "chinese 15 8", "hijri 1 9",
+}
+
+// Ошибочные правила, на них и модуль, и сервер должны вернуть ошибку
+var invalid = []string{
+	"", "d 401", "w 8", "m 30 2", "m 31 2,4", "mw 1.1,-1.5", "x missing", "k 34",
+}
+
+var repeats = append(append([]string{}, valid...), invalid...)
+
+func TestMain(m *testing.M) {
+	register()
+	os.Exit(m.Run())
+}
+
+// call вызывает функцию из nextdate так же, как её вызывает страница
+func call(name string, args ...any) map[string]string {
+	value := js.Global().Get("nextdate").Call(name, args...)
+	result := map[string]string{}
+	for _, key := range []string{"date", "repeat", "description", "error"} {
+		if field := value.Get(key); field.Type() == js.TypeString {
+			result[key] = field.String()
+		}
+	}
+	return result
+}
+
+func TestNextDate(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.Local)
+	for _, repeat := range repeats {
+		for _, date := range []string{"20240126", "20240229", "20231231", "2024"} {
+			got := call("next", now.Format("20060102"), date, repeat)
+			want, err := nd.NextDate(now, date, repeat)
+			switch {
+			case err != nil && got["error"] != err.Error():
+				t.Errorf("next(%q, %s): ошибка %q, ожидалась %q", repeat, date, got["error"], err)
+			case err == nil && got["date"] != want:
+				t.Errorf("next(%q, %s): %q (%s), ожидалось %s", repeat, date, got["date"], got["error"], want)
+			}
+		}
+	}
+
+	if got := call("next", "20240126", "20240126"); got["error"] == "" {
+		t.Error("next без repeat: ожидалась ошибка")
+	}
+	if got := call("next", "ooops", "20240126", "d 1"); got["error"] == "" {
+		t.Error("next с неверным now: ожидалась ошибка")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, repeat := range repeats {
+		got := call("validate", repeat)
+		rule, err := nd.Parse(repeat)
+		switch {
+		case err != nil && got["error"] != err.Error():
+			t.Errorf("validate(%q): ошибка %q, ожидалась %q", repeat, got["error"], err)
+		case err == nil && got["repeat"] != rule.String():
+			t.Errorf("validate(%q): %q (%s), ожидалось %q", repeat, got["repeat"], got["error"], rule.String())
+		}
+	}
+
+	// Сверка с сервером не поймает правило, которое ошибочно разбирается в обоих местах
+	for _, repeat := range valid {
+		if got := call("validate", repeat); got["error"] != "" || got["repeat"] == "" {
+			t.Errorf("validate(%q): %q, ожидалось правило без ошибки", repeat, got["error"])
+		}
+	}
+	for _, repeat := range invalid {
+		if got := call("validate", repeat); got["error"] == "" {
+			t.Errorf("validate(%q): %q, ожидалась ошибка", repeat, got["repeat"])
+		}
+		if got := call("next", "20240126", "20240126", repeat); got["error"] == "" {
+			t.Errorf("next(%q): %q, ожидалась ошибка", repeat, got["date"])
+		}
+	}
+
+	if got := call("validate", 42); got["error"] == "" {
+		t.Error("validate с числом: ожидалась ошибка")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	for _, repeat := range repeats {
+		for _, lang := range []string{"ru", "en", ""} {
+			got := call("describe", repeat, lang)
+			want, err := nd.Describe(repeat, lang)
+			switch {
+			case err != nil && got["error"] != err.Error():
+				t.Errorf("describe(%q, %q): ошибка %q, ожидалась %q", repeat, lang, got["error"], err)
+			case err == nil && got["description"] != want:
+				t.Errorf("describe(%q, %q): %q (%s), ожидалось %q", repeat, lang, got["description"], got["error"], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !js
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Тесты экспортов работают внутри WebAssembly, поэтому обычный go test запускает их через node.
+// Без node или с -short тест пропускается.
+func TestWasmExports(t *testing.T) {
+	if testing.Short() {
+		t.Skip("сборка в WebAssembly долгая, пропускаем с -short")
+	}
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("нет node, тесты WebAssembly не запустить")
+	}
+
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// go_js_wasm_exec в новых версиях Go лежит в lib/wasm, в старых - в misc/wasm
+	root := strings.TrimSpace(string(goroot))
+	path := strings.Join([]string{
+		filepath.Join(root, "lib", "wasm"),
+		filepath.Join(root, "misc", "wasm"),
+		os.Getenv("PATH"),
+	}, string(os.PathListSeparator))
+
+	cmd := exec.Command("go", "test", "-count=1", ".")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm", "PATH="+path)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("тесты WebAssembly упали: %s\n%s", err, out)
+	}
+}
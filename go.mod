@@ -8,6 +8,7 @@ require (
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/stretchr/testify v1.9.0
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
 	modernc.org/sqlite v1.34.1
 )
 
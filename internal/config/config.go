@@ -7,9 +7,13 @@ import (
 
 // Создадим структуру описывающую наш конфиг
 type Config struct {
-	HTTPAdress string
-	DBPath     string
-	WebDir     string
+	HTTPAdress   string
+	DBPath       string
+	WebDir       string
+	HolidaysPath string // Файл праздников для рабочих дней, пустой - только выходные
+	TimeZone     string // Часовой пояс пользователей по умолчанию, пустой - пояс сервера
+	MonthEnd     string // Политика для 31 апреля и 29 февраля по умолчанию для m, y и других календарей: overflow, clamp или skip
+	RulesDir     string // Папка со скриптами правил x на Starlark
 }
 
 func Load() *Config {
@@ -37,5 +41,22 @@ func Load() *Config {
 		cfg.WebDir = webDir
 	}
 
+	// Файл праздников необязателен, без него рабочими считаются будни
+	cfg.HolidaysPath = os.Getenv("TODO_HOLIDAYS")
+
+	// Часовой пояс задаём именем из базы IANA, например Europe/Moscow
+	cfg.TimeZone = os.Getenv("TODO_TZ")
+
+	// Пустая политика оставляет правила как есть: y переполняет, m пропускает
+	cfg.MonthEnd = os.Getenv("TODO_MONTHEND")
+
+	// Скрипты правил x лежат рядом с web, в контейнере путь передаётся через енв
+	rulesDir := os.Getenv("TODO_RULES")
+	if rulesDir == "" {
+		cfg.RulesDir = "./rules"
+	} else {
+		cfg.RulesDir = rulesDir
+	}
+
 	return &cfg
 }
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"time"
 
 	nd "github.com/fedgolang/go_final_project/internal/lib/nextdate"
@@ -19,8 +21,15 @@ import (
 
 var (
 	limitForTasks = 50                                                                         // Максимальное кол-во возвращаемых тасков в GetTasks
+	limitForDates = 100                                                                        // Максимальное кол-во дат в Occurrences
+	limitForDays  = 366                                                                        // Максимальная длина периода в Calendar, в днях
+	limitForTask  = 1000                                                                       // Максимальное кол-во повторений одной таски в Calendar
 	JWTSecret     = []byte("69612fb755d66b4a275896981874c46210f4afbac7673bcb0ce40d3c6a0160d5") // Секрет для токена
 	envPass       = os.Getenv("TODO_PASSWORD")                                                 //
+
+	// Часовой пояс пользователя по умолчанию, от него считается "сегодня".
+	// Запрос может переопределить его заголовком X-Timezone или параметром tz.
+	DefaultLocation = time.Local
 )
 
 type SignInRequest struct {
@@ -44,6 +53,57 @@ type TasksResponse struct {
 	Tasks []storage.TaskNoEmpty `json:"tasks"`
 }
 
+// Повторение таски в календаре, дата и время уже конкретного повторения
+type CalendarTask struct {
+	storage.TaskNoEmpty
+	Projected bool `json:"projected"` // Повторение вычислено по правилу, в БД у таски другая дата
+}
+
+// День календаря со всеми тасками на него
+type CalendarDay struct {
+	Date  string         `json:"date"`
+	Tasks []CalendarTask `json:"tasks"`
+}
+
+// Структура для ответа календаря, в нём только дни, на которые что-то есть
+type CalendarResponse struct {
+	Days []CalendarDay `json:"days"`
+
+	// У какой-то таски повторений в периоде больше limitForTask, показаны только первые из них
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Структура для ответа с описанием правила повторения
+type DescribeResponse struct {
+	Description string   `json:"description,omitempty"`
+	Next        string   `json:"next,omitempty"`
+	Steps       []string `json:"steps,omitempty"`
+	Err         string   `json:"error,omitempty"`
+}
+
+// Структура для ответа со списком пауз
+type PausesResponse struct {
+	Pauses []storage.Pause `json:"pauses"`
+}
+
+// Задача, которую сдвигает пауза: текущая дата и дата после паузы
+type ShiftedTask struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Date    string `json:"date"`
+	Time    string `json:"time,omitempty"`
+	NewDate string `json:"new_date,omitempty"`
+	NewTime string `json:"new_time,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"` // Серия закончилась раньше, чем пауза, задача удаляется
+	Skipped string `json:"skipped,omitempty"` // Почему задача осталась на месте, например правило больше не разбирается
+}
+
+// Структура для ответа на сдвиг задач паузой
+type ShiftResponse struct {
+	DryRun bool          `json:"dry_run"`
+	Tasks  []ShiftedTask `json:"tasks"`
+}
+
 // Для реализации всех хендлеров будем пользоваться middleware
 
 // Хендлер отвечает за добавление таски в БД
@@ -68,6 +128,14 @@ func PostTask(s *storage.Scheduler) http.HandlerFunc {
 			return
 		}
 
+		// Несколько правил приходят списком, храним их одной строкой
+		err = joinRepeats(&task)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
 		// Проверим, что заголовок не пустой
 		if task.Title == "" {
 			resp.Err = "Не указан заголовок задачи" // 400 пришел пустой title
@@ -75,42 +143,82 @@ func PostTask(s *storage.Scheduler) http.HandlerFunc {
 			return
 		}
 
+		// "Сегодня" считаем в часовом поясе пользователя
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		now := time.Now().In(loc)
+
 		// Проверим, что дата не пустая
 		if task.Date == "" {
-			task.Date = time.Now().Format("20060102")
+			task.Date = now.Format("20060102")
 		}
 
-		date, err := time.Parse("20060102", task.Date)
+		date, err := time.ParseInLocation("20060102", task.Date, loc)
 		if err != nil {
 			resp.Err = "Неверный формат даты, ожидается ГГГГММДД"
 			prepareJSONResp(w, 400, resp)
 			return
 		}
 
+		// Время необязательное, но если есть, то в формате ЧЧ:ММ
+		task.Time, err = validateTime(task.Time)
+		if err != nil {
+			resp.Err = "Неверный формат времени, ожидается ЧЧ:ММ"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		created := task
+
 		// Проверим, что дата не прошлое, если повторения нет
 		if task.Repeat == "" {
-			if date.Before(time.Now()) {
-				task.Date = time.Now().Format("20060102")
+			if date.Before(now) {
+				task.Date = now.Format("20060102")
 			}
 		} else { // Если не пустое повторение, вычислим следующую дату из NextDate()
+			// Правило проверяем всегда, даже если дату пересчитывать не придётся,
+			// иначе в БД попадёт задача, которая никогда не повторится
+			err = checkFires(task.Repeat, date)
+			if err != nil {
+				resp.Err = fmt.Sprint(err)
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+
 			// Доп проверка, если таска добавляется сегодня с датой > сегодня
 			// То не учитываем NextDate и регаем таску с датой = дате создания
-			if date.Truncate(24 * time.Hour).Before(time.Now().Truncate(24 * time.Hour)) {
-				task.Date, err = nd.NextDate(time.Now(), task.Date, task.Repeat)
+			if task.Date < now.Format("20060102") {
+				nextDate, nextTime, err := nd.NextDateTime(now, task.Date, task.Time, task.Repeat)
 				if err != nil {
 					resp.Err = fmt.Sprint(err)
 					prepareJSONResp(w, 400, resp)
 					return
 				}
+				task.Date, task.Time = nextDate, nextTime
 			}
 		}
 
-		// Проводим запись в БД
-		id, err := s.PostTask(task)
+		// Для серии с ограничением count запомним, сколько повторений осталось
+		series := storage.Series{}
+		series.Remaining, series.Counted, err = seriesRemaining(s, created, task, loc, true)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 500, resp)
+			return
+		}
+		if task.Repeat != "" {
+			series.Origin = created.Date
+		}
+
+		// Проводим запись в БД вместе с серией, чтобы при ошибке не осталось задачи без неё
+		id, err := s.PostTaskSeries(task, series)
 		if err != nil {
 			// Так как описывает ошибку в самом методе, просто запишем её тут
 			resp.Err = fmt.Sprint(err)
-			prepareJSONResp(w, 400, resp)
+			prepareJSONResp(w, 500, resp)
 			return
 		}
 		resp.ID = id
@@ -119,12 +227,19 @@ func PostTask(s *storage.Scheduler) http.HandlerFunc {
 	}
 }
 
-// Ручка, чтобы отдельно дёрнуть проверку даты
+// Ручка, чтобы отдельно дёрнуть проверку даты.
+// Паузы привязаны к задачам в БД, поэтому здесь правило считается без них.
 func NextDateHand(w http.ResponseWriter, r *http.Request) {
 	// Объявим переменные и достанем параметры
 	resp := Response{}
+	loc, err := userLocation(r)
+	if err != nil {
+		resp.Err = fmt.Sprint(err)
+		prepareJSONResp(w, 400, resp)
+		return
+	}
 	now := r.URL.Query().Get("now")
-	nowDate, err := time.Parse("20060102", now)
+	nowDate, err := time.ParseInLocation("20060102", now, loc)
 	if err != nil {
 		resp.Err = "Неверный формат даты"
 		prepareJSONResp(w, 400, resp)
@@ -135,6 +250,11 @@ func NextDateHand(w http.ResponseWriter, r *http.Request) {
 
 	// Вычисляем следующую дату
 	nextDate, err := nd.NextDate(nowDate, date, repeat)
+	if errors.Is(err, nd.ErrSeriesFinished) || errors.Is(err, nd.ErrNeverFires) {
+		resp.Err = fmt.Sprint(err)
+		prepareJSONResp(w, 400, resp)
+		return
+	}
 	if err != nil {
 		resp.Err = "Неверный формат даты"
 		prepareJSONResp(w, 400, resp)
@@ -148,13 +268,118 @@ func NextDateHand(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(nextDate))
 }
 
+// Ручка для предпросмотра нескольких ближайших дат по правилу
+func Occurrences(w http.ResponseWriter, r *http.Request) {
+	resp := Response{}
+	loc, err := userLocation(r)
+	if err != nil {
+		resp.Err = fmt.Sprint(err)
+		prepareJSONResp(w, 400, resp)
+		return
+	}
+
+	// Если now не передали, считаем от сегодня
+	nowDate := time.Now().In(loc)
+	if now := r.URL.Query().Get("now"); now != "" {
+		nowDate, err = time.ParseInLocation("20060102", now, loc)
+		if err != nil {
+			resp.Err = "Неверный формат даты"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = nowDate.Format("20060102")
+	}
+	repeat := r.URL.Query().Get("repeat")
+
+	// По умолчанию отдаём 10 дат, но не больше лимита
+	n := 10
+	if count := r.URL.Query().Get("n"); count != "" {
+		n, err = strconv.Atoi(count)
+		if err != nil || n < 1 {
+			resp.Err = "Некорректное количество дат"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+	}
+	if n > limitForDates {
+		n = limitForDates
+	}
+
+	dates, err := nd.NextDates(nowDate, date, repeat, n)
+	if err != nil {
+		resp.Err = fmt.Sprint(err)
+		prepareJSONResp(w, 400, resp)
+		return
+	}
+
+	prepareJSONResp(w, 200, dates)
+}
+
+// Ручка для описания правила повторения человеческим языком.
+// Если передана дата задачи, дополнительно объясняет, как получилась следующая дата.
+func DescribeRepeat(w http.ResponseWriter, r *http.Request) {
+	resp := DescribeResponse{}
+	repeat := r.URL.Query().Get("repeat")
+	lang := r.URL.Query().Get("lang")
+
+	description, err := nd.Describe(repeat, lang)
+	if err != nil {
+		resp.Err = fmt.Sprint(err)
+		prepareJSONResp(w, 400, resp)
+		return
+	}
+	resp.Description = description
+
+	// Без даты задачи объяснять нечего, отдаём только описание
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		prepareJSONResp(w, 200, resp)
+		return
+	}
+
+	loc, err := userLocation(r)
+	if err != nil {
+		resp.Err = fmt.Sprint(err)
+		prepareJSONResp(w, 400, resp)
+		return
+	}
+	nowDate := time.Now().In(loc)
+	if now := r.URL.Query().Get("now"); now != "" {
+		nowDate, err = time.ParseInLocation("20060102", now, loc)
+		if err != nil {
+			resp.Err = "Неверный формат даты"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+	}
+
+	resp.Steps, resp.Next, err = nd.Explain(nowDate, date, repeat, lang)
+	if err != nil {
+		resp.Err = "Неверный формат даты"
+		prepareJSONResp(w, 400, resp)
+		return
+	}
+
+	prepareJSONResp(w, 200, resp)
+}
+
 // Хендлер отвечает за возвращение набора тасок
 func GetTasks(s *storage.Scheduler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Объявим пустой слайс tasks, для случая если приходит пустой ответ из БД
 		tasks := TasksResponse{Tasks: []storage.TaskNoEmpty{}}
 		resp := Response{}
-		today := time.Now().Format(`20060102`)
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		today := time.Now().In(loc).Format(`20060102`)
 
 		// Попробуем достать GET параметр search
 		search := r.URL.Query().Get("search")
@@ -162,7 +387,6 @@ func GetTasks(s *storage.Scheduler) http.HandlerFunc {
 		searchDate, okDate := validateAndFormatDate(search)
 
 		var dbTasks []storage.TaskNoEmpty
-		var err error
 
 		// В зависимости от полученных данных по поиску, запустим функции для БД
 		if okDate {
@@ -185,6 +409,86 @@ func GetTasks(s *storage.Scheduler) http.HandlerFunc {
 	}
 }
 
+// Хендлер отвечает за календарь на период from - to включительно.
+// Повторяющиеся таски раскладываются по всем своим датам в периоде, а не только по ближайшей.
+func Calendar(s *storage.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := Response{}
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Без периода показываем неделю начиная с сегодня
+		from := time.Now().In(loc)
+		if param := r.URL.Query().Get("from"); param != "" {
+			from, err = time.ParseInLocation("20060102", param, loc)
+			if err != nil {
+				resp.Err = "Неверный формат даты"
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+		}
+		to := from.AddDate(0, 0, 6)
+		if param := r.URL.Query().Get("to"); param != "" {
+			to, err = time.ParseInLocation("20060102", param, loc)
+			if err != nil {
+				resp.Err = "Неверный формат даты"
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+		}
+		if to.Before(from) {
+			resp.Err = "Конец периода раньше начала"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		if to.After(from.AddDate(0, 0, limitForDays)) {
+			resp.Err = fmt.Sprintf("Период не может быть длиннее %d дней", limitForDays)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		fromDate, toDate := from.Format("20060102"), to.Format("20060102")
+
+		tasks, err := s.GetTasksForPeriod(fromDate, toDate)
+		if err != nil {
+			resp.Err = fmt.Sprintf("ошибка при запросе задач: %s", err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		days := map[string][]CalendarTask{}
+		calendar := CalendarResponse{Days: []CalendarDay{}}
+		for _, task := range tasks {
+			occurrences, truncated, err := taskOccurrences(s, task, from, to)
+			if err != nil {
+				resp.Err = fmt.Sprintf("ошибка при расчёте повторений: %s", err)
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+			calendar.Truncated = calendar.Truncated || truncated
+			for _, occurrence := range occurrences {
+				days[occurrence.Date] = append(days[occurrence.Date], occurrence)
+			}
+		}
+
+		// Дни по порядку, внутри дня таски на весь день идут первыми, дальше по времени
+		for date, dayTasks := range days {
+			sort.SliceStable(dayTasks, func(i, j int) bool {
+				return dayTasks[i].Time < dayTasks[j].Time
+			})
+			calendar.Days = append(calendar.Days, CalendarDay{Date: date, Tasks: dayTasks})
+		}
+		sort.Slice(calendar.Days, func(i, j int) bool {
+			return calendar.Days[i].Date < calendar.Days[j].Date
+		})
+
+		prepareJSONResp(w, 200, calendar)
+	}
+}
+
 // Хендлер отвечает за поиск по ID таски в БД
 func GetDataForEdit(s *storage.Scheduler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -208,6 +512,10 @@ func GetDataForEdit(s *storage.Scheduler) http.HandlerFunc {
 			prepareJSONResp(w, 400, resp)
 			return
 		}
+		// Несколько правил отдаём ещё и списком, так их удобнее редактировать
+		if rules := nd.SplitRules(task.Repeat); len(rules) > 1 {
+			task.Repeats = rules
+		}
 
 		prepareJSONResp(w, 200, task)
 	}
@@ -235,6 +543,14 @@ func PutDataByID(s *storage.Scheduler) http.HandlerFunc {
 			return
 		}
 
+		// Несколько правил приходят списком, храним их одной строкой
+		err = joinRepeats(&task)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
 		// Проверим, что заголовок не пустой
 		if task.Title == "" {
 			resp.Err = "Не указан заголовок задачи"
@@ -242,23 +558,41 @@ func PutDataByID(s *storage.Scheduler) http.HandlerFunc {
 			return
 		}
 
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
 		// Проверим, что дата не пустая
 		if task.Date == "" {
-			task.Date = time.Now().Format("20060102")
+			task.Date = time.Now().In(loc).Format("20060102")
 		}
 
 		// Проверим, что формат даты ожидаемый
-		_, err = time.Parse("20060102", task.Date)
+		date, err := time.ParseInLocation("20060102", task.Date, loc)
 		if err != nil {
 			resp.Err = "Неверный формат даты ожидается ГГГГММДД"
 			prepareJSONResp(w, 400, resp)
 			return
 		}
 
-		// Так как проверка repeat у нас внутри NextDate
-		// Запустим функцию без сохранения переменной для проверки на ошибки
+		task.Time, err = validateTime(task.Time)
+		if err != nil {
+			resp.Err = "Неверный формат времени, ожидается ЧЧ:ММ"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Дату тут считать не нужно, достаточно убедиться, что правило от даты задачи сработает
 		if task.Repeat != "" {
-			_, err = nd.NextDate(time.Now(), task.Date, task.Repeat)
+			err = checkFires(task.Repeat, date)
+			if errors.Is(err, nd.ErrNeverFires) {
+				resp.Err = fmt.Sprint(err)
+				prepareJSONResp(w, 400, resp)
+				return
+			}
 			if err != nil {
 				resp.Err = "Неверный формат repeat"
 				prepareJSONResp(w, 400, resp)
@@ -266,8 +600,37 @@ func PutDataByID(s *storage.Scheduler) http.HandlerFunc {
 			}
 		}
 
-		// Отправим таску на апдейт в БД
-		err = s.EditTask(task)
+		// Запомним прошлое правило, чтобы понять, начинается ли серия заново
+		oldTask, err := s.GetTaskByID(task.ID)
+		if err != nil {
+			// Ошибку описываем внутри метода
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Если поменяли дату или правило, серия начинается заново с даты задачи
+		series := storage.Series{}
+		if oldTask.Date != task.Date || oldTask.Repeat != task.Repeat {
+			series.Origin = task.Date
+		}
+
+		// Если правило поменяли, серия с count и карточка начинаются заново с даты задачи,
+		// иначе остаток остаётся прежним
+		if oldTask.Repeat != task.Repeat {
+			series.Restart = true
+			series.Remaining, series.Counted, err = seriesRemaining(s, task, task, loc, true)
+		} else {
+			series.Remaining, series.Counted, err = s.GetRemaining(task.ID)
+		}
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 500, resp)
+			return
+		}
+
+		// Отправим таску на апдейт в БД вместе с серией
+		err = s.EditTaskSeries(task, series)
 		if errors.Is(err, sql.ErrNoRows) {
 			resp.Err = "Задача не найдена"
 			prepareJSONResp(w, 400, resp)
@@ -275,7 +638,7 @@ func PutDataByID(s *storage.Scheduler) http.HandlerFunc {
 		}
 		if err != nil {
 			resp.Err = "Возникла проблема с изменением задачи"
-			prepareJSONResp(w, 400, resp)
+			prepareJSONResp(w, 500, resp)
 			return
 		}
 
@@ -308,6 +671,33 @@ func TaskDone(s *storage.Scheduler) http.HandlerFunc {
 			return
 		}
 
+		// Следующую дату считаем от "сегодня" пользователя
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Оценка выполнения бывает только у интервального повторения, по умолчанию good
+		grade := r.URL.Query().Get("grade")
+		if task.Repeat != "" {
+			rule, err := nd.ParseCached(task.Repeat)
+			if err == nil && rule.Kind == nd.KindSpaced && grade == "" {
+				grade = nd.GradeGood
+			}
+			if err == nil && rule.Kind != nd.KindSpaced && grade != "" {
+				resp.Err = "Оценку выполнения можно передать только для правила sr"
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+		}
+		if grade != "" && !nd.IsGrade(grade) {
+			resp.Err = "Оценка выполнения должна быть again, hard, good или easy"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
 		// Если правил повторения нет, просто удаляем задачу
 		if task.Repeat == "" {
 			err := s.DeleteTaskByID(taskID)
@@ -317,18 +707,71 @@ func TaskDone(s *storage.Scheduler) http.HandlerFunc {
 				return
 			}
 		} else {
-			// Если есть правило, вычислим дату
-			nextDate, err := nd.NextDate(time.Now(), task.Date, task.Repeat)
+			// Если есть правило, переносим задачу на следующую дату
+			err = advanceTask(s, task, time.Now().In(loc), grade)
 			if err != nil {
 				resp.Err = fmt.Sprint(err)
 				prepareJSONResp(w, 400, resp)
 				return
 			}
-			// Проблем при вычислении даты не возникло, присвоим новую дату и отправим на изменение
-			task.Date = nextDate
-			err = s.EditTask(task)
+		}
+
+		prepareJSONResp(w, 200, resp)
+	}
+}
+
+// Хендлер отвечает за пропуск одного повторения задачи
+func SkipTask(s *storage.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := Response{}
+
+		taskID := r.URL.Query().Get("id")
+		if taskID == "" {
+			resp.Err = "Не указан идентификатор"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		date := r.URL.Query().Get("date")
+		_, err := time.Parse("20060102", date)
+		if err != nil {
+			resp.Err = "Неверный формат даты, ожидается ГГГГММДД"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		task, err := s.GetTaskByID(taskID)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Пропускать можно только повторения, у разовой задачи их нет
+		if task.Repeat == "" {
+			resp.Err = "У задачи нет правила повторения"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		err = s.AddSkip(taskID, date)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Если пропускают текущее повторение, сразу переносим задачу дальше
+		if task.Date == date {
+			err = advanceTask(s, task, time.Now().In(loc), "")
 			if err != nil {
-				// Ошибку описываем в методе, тут просто запишем
 				resp.Err = fmt.Sprint(err)
 				prepareJSONResp(w, 400, resp)
 				return
@@ -367,6 +810,176 @@ func DeleteTask(s *storage.Scheduler) http.HandlerFunc {
 	}
 }
 
+// Хендлер отвечает за добавление паузы повторений, общей или для одной задачи
+func PostPause(s *storage.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pause := storage.Pause{}
+		resp := Response{}
+		var buf bytes.Buffer
+
+		_, err := buf.ReadFrom(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = json.Unmarshal(buf.Bytes(), &pause)
+		if err != nil {
+			resp.Err = "Ошибка десериализации JSON"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		_, _, err = nd.ParsePause(pause.From, pause.To, loc)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Пауза для одной задачи, задача должна существовать
+		if pause.TaskID != 0 {
+			_, err = s.GetTaskByID(strconv.FormatInt(pause.TaskID, 10))
+			if err != nil {
+				resp.Err = fmt.Sprint(err)
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+		}
+
+		id, err := s.AddPause(pause)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		resp.ID = int(id)
+
+		prepareJSONResp(w, 201, resp)
+	}
+}
+
+// Хендлер отвечает за вывод всех пауз
+func GetPauses(s *storage.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := Response{}
+
+		pauses, err := s.GetAllPauses()
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		prepareJSONResp(w, 200, PausesResponse{Pauses: pauses})
+	}
+}
+
+// Хендлер отвечает за удаление паузы, уже сдвинутые задачи остаются на новых датах
+func DeletePause(s *storage.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := Response{}
+
+		pauseID := r.URL.Query().Get("id")
+		if pauseID == "" {
+			resp.Err = "Не указан идентификатор"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		err := s.DeletePause(pauseID)
+		if errors.Is(err, sql.ErrNoRows) {
+			resp.Err = "Пауза не найдена"
+			prepareJSONResp(w, 400, resp)
+			return
+		} else if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		prepareJSONResp(w, 200, resp)
+	}
+}
+
+// Хендлер отвечает за сдвиг всех задач, чьи даты попали в паузу, на первую дату после неё.
+// Повторяющаяся задача встаёт на своё первое повторение после паузы, разовая - на следующий за паузой день.
+// С параметром dry_run задачи не меняются, ответ только показывает, что куда переедет.
+func ShiftPause(s *storage.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := Response{}
+
+		pauseID := r.URL.Query().Get("id")
+		if pauseID == "" {
+			resp.Err = "Не указан идентификатор"
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		dryRun := false
+		if param := r.URL.Query().Get("dry_run"); param != "" {
+			var err error
+			dryRun, err = strconv.ParseBool(param)
+			if err != nil {
+				resp.Err = "Параметр dry_run должен быть true или false"
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+		}
+
+		loc, err := userLocation(r)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		pause, err := s.GetPauseByID(pauseID)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+		_, end, err := nd.ParsePause(pause.From, pause.To, loc)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		// Общая пауза сдвигает все задачи, пауза задачи - только её саму
+		taskID := ""
+		if pause.TaskID != 0 {
+			taskID = strconv.FormatInt(pause.TaskID, 10)
+		}
+		tasks, err := s.GetTasksInRange(pause.From, pause.To, taskID)
+		if err != nil {
+			resp.Err = fmt.Sprint(err)
+			prepareJSONResp(w, 400, resp)
+			return
+		}
+
+		shift := ShiftResponse{DryRun: dryRun, Tasks: []ShiftedTask{}}
+		for _, task := range tasks {
+			shifted, err := shiftTask(s, task, end, dryRun)
+			if err != nil {
+				resp.Err = fmt.Sprintf("задача %s: %s", task.ID, err)
+				prepareJSONResp(w, 400, resp)
+				return
+			}
+			shift.Tasks = append(shift.Tasks, shifted)
+		}
+
+		prepareJSONResp(w, 200, shift)
+	}
+}
+
 // Хендлер аутентификации
 func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	// Для аутентификации поставим проверку на метод
@@ -462,6 +1075,297 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// Функция переносит повторяющуюся задачу на следующую дату.
+// Если серия закончилась, задача удаляется. grade - оценка выполнения для правила sr,
+// пустая, если повторение не выполнили, а пропустили.
+func advanceTask(s *storage.Scheduler, task storage.Task, now time.Time, grade string) error {
+	nextDate, nextTime, err := nextTaskDate(s, task, now, grade)
+	if errors.Is(err, nd.ErrSeriesFinished) {
+		// Повторений больше не будет, задача отработала своё
+		err = s.DeleteTaskByID(task.ID)
+		if err != nil {
+			return fmt.Errorf("не удалось удалить задачу")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// У задач, созданных до появления начала серии, им считается дата до первого переноса
+	series := storage.Series{}
+	_, ok, err := s.GetOrigin(task.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		series.Origin = task.Date
+	}
+
+	// Проблем при вычислении даты не возникло, присвоим новую дату и посчитаем серию после переноса
+	prev := task
+	task.Date, task.Time = nextDate, nextTime
+	series.Remaining, series.Counted, err = seriesRemaining(s, prev, task, now.Location(), false)
+	if err != nil {
+		return err
+	}
+	series.Review, err = gradedReview(s, task, grade)
+	if err != nil {
+		return err
+	}
+
+	// Дату, остаток и карточку пишем одной транзакцией, чтобы серия не разошлась с задачей
+	return s.EditTaskSeries(task, series)
+}
+
+// Функция вычисляет следующую дату и время задачи.
+// Для серии с count вместо полного количества берётся сохранённый в БД остаток,
+// пропущенные даты задачи перепрыгиваются. Для интервального повторения
+// следующая дата зависит от оценки выполнения grade.
+func nextTaskDate(s *storage.Scheduler, task storage.Task, now time.Time, grade string) (string, string, error) {
+	rule, err := taskRule(s, task.ID, task.Repeat)
+	if err != nil {
+		return "", "", err
+	}
+	rule.Grade = grade
+
+	return rule.NextDateTime(now, task.Date, task.Time)
+}
+
+// Функция переносит задачу с даты внутри паузы на первую дату после её последнего дня end.
+// Паузы задачи уже в БД, поэтому повторение просто считается от конца паузы.
+// При dryRun задача не меняется, только считается её новая дата.
+func shiftTask(s *storage.Scheduler, task storage.Task, end time.Time, dryRun bool) (ShiftedTask, error) {
+	shifted := ShiftedTask{ID: task.ID, Title: task.Title, Date: task.Date, Time: task.Time}
+
+	// Разовая задача переезжает на следующий за паузой день
+	if task.Repeat == "" {
+		shifted.NewDate, shifted.NewTime = end.AddDate(0, 0, 1).Format("20060102"), task.Time
+		if dryRun {
+			return shifted, nil
+		}
+		task.Date = shifted.NewDate
+		return shifted, s.EditTask(task)
+	}
+
+	// Задачу с неразбираемым правилом не двигаем: куда её переносить, непонятно
+	_, err := nd.ParseCached(task.Repeat)
+	if err != nil {
+		shifted.Skipped = fmt.Sprintf("правило %q не разбирается: %s", task.Repeat, err)
+		return shifted, nil
+	}
+
+	shifted.NewDate, shifted.NewTime, err = nextTaskDate(s, task, end, "")
+	if errors.Is(err, nd.ErrSeriesFinished) {
+		shifted.NewDate, shifted.NewTime, shifted.Deleted = "", "", true
+	} else if err != nil {
+		return shifted, err
+	}
+	if dryRun {
+		return shifted, nil
+	}
+	return shifted, advanceTask(s, task, end, "")
+}
+
+// Функция проверяет, что правило от даты задачи хоть раз сработает. Разбора мало: RRULE с INTERVAL
+// или скрипт могут не давать дат только при некоторых датах начала. Поиск даты ограничен в самих правилах.
+func checkFires(repeat string, date time.Time) error {
+	rule, err := nd.ParseCached(repeat)
+	if err != nil {
+		return err
+	}
+	// Закончившаяся серия не ошибка, задача просто больше не повторится
+	_, err = rule.Next(date, date)
+	if errors.Is(err, nd.ErrNeverFires) {
+		return err
+	}
+	return nil
+}
+
+// Функция переводит паузы из БД в паузы правила
+func rulePauses(pauses []storage.Pause) []nd.Pause {
+	result := make([]nd.Pause, 0, len(pauses))
+	for _, pause := range pauses {
+		result = append(result, nd.Pause{From: pause.From, To: pause.To})
+	}
+	return result
+}
+
+// Функция раскладывает задачу по её датам в периоде from - to.
+// Разовая задача или задача с неразбираемым правилом остаётся на своей дате.
+// true - повторений в периоде больше limitForTask, вернулись только первые.
+func taskOccurrences(s *storage.Scheduler, task storage.TaskNoEmpty, from, to time.Time) ([]CalendarTask, bool, error) {
+	stored := CalendarTask{TaskNoEmpty: task}
+	rule, err := taskRule(s, task.ID, task.Repeat)
+	if task.Repeat == "" || err != nil {
+		if task.Date < from.Format("20060102") {
+			return nil, false, nil
+		}
+		return []CalendarTask{stored}, false, nil
+	}
+
+	rule, anchor, err := rule.Anchor(task.Date, task.Time, from.Location())
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Берём на одно повторение больше лимита, чтобы понять, что в период влезли не все
+	dates := rule.Between(anchor, from, to, limitForTask+1)
+	truncated := len(dates) > limitForTask
+	if truncated {
+		dates = dates[:limitForTask]
+	}
+
+	occurrences := []CalendarTask{}
+	for _, date := range dates {
+		occurrence := stored
+		occurrence.Date = date.Format("20060102")
+		if rule.HasTime() {
+			occurrence.Time = date.Format("15:04")
+		}
+		occurrence.Projected = !date.Equal(anchor)
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, truncated, nil
+}
+
+// Функция разбирает правило задачи и дополняет его тем, что хранится в БД:
+// остатком серии с count, началом серии, пропущенными датами, паузами и состоянием карточки
+func taskRule(s *storage.Scheduler, id, repeat string) (nd.Rule, error) {
+	rule, err := nd.ParseCached(repeat)
+	if err != nil {
+		return rule, err
+	}
+
+	if rule.Count > 0 {
+		remaining, ok, err := s.GetRemaining(id)
+		if err != nil {
+			return rule, err
+		}
+		if ok {
+			rule.Count = remaining
+		}
+	}
+
+	rule.Origin, _, err = s.GetOrigin(id)
+	if err != nil {
+		return rule, err
+	}
+
+	rule.Except, err = s.GetSkips(id)
+	if err != nil {
+		return rule, err
+	}
+
+	pauses, err := s.GetPauses(id)
+	if err != nil {
+		return rule, err
+	}
+	rule.Pauses = rulePauses(pauses)
+
+	if rule.Kind == nd.KindSpaced {
+		review, _, err := s.GetReview(id)
+		if err != nil {
+			return rule, err
+		}
+		rule.Review = nd.Review{Step: review.Step, Ease: review.Ease, Interval: review.Interval}
+	}
+
+	return rule, nil
+}
+
+// Функция считает, как продвинулась карточка интервального повторения после выполнения.
+// Без оценки задачу не выполняли, а пропустили, карточка остаётся как была и вернётся nil.
+func gradedReview(s *storage.Scheduler, task storage.Task, grade string) (*storage.Review, error) {
+	if grade == "" {
+		return nil, nil
+	}
+	rule, err := taskRule(s, task.ID, task.Repeat)
+	if err != nil || rule.Kind != nd.KindSpaced {
+		return nil, err
+	}
+
+	review := rule.Graded(grade)
+	return &storage.Review{Step: review.Step, Ease: review.Ease, Interval: review.Interval}, nil
+}
+
+// Функция считает остаток серии после переноса задачи из prev в task, false - у правила нет count.
+// Даты задачи считаются в поясе пользователя loc. restart - серия начинается заново,
+// сохранённый в БД остаток не учитывается.
+func seriesRemaining(s *storage.Scheduler, prev, task storage.Task, loc *time.Location, restart bool) (int, bool, error) {
+	rule, err := nd.ParseCached(task.Repeat)
+	if err != nil || rule.Count == 0 || task.Date == "" {
+		return 0, false, nil
+	}
+
+	rule, from, err := rule.Anchor(prev.Date, prev.Time, loc)
+	if err != nil {
+		return 0, false, err
+	}
+	_, to, err := rule.Anchor(task.Date, task.Time, loc)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Если задача уже шла по серии, считаем от сохранённого остатка
+	if !restart {
+		remaining, ok, err := s.GetRemaining(task.ID)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			rule.Count = remaining
+		}
+	}
+
+	return rule.Remaining(from, to), true, nil
+}
+
+// Функция определяет часовой пояс пользователя.
+// Пояс берётся из заголовка X-Timezone или параметра tz, иначе DefaultLocation.
+func userLocation(r *http.Request) (*time.Location, error) {
+	name := r.Header.Get("X-Timezone")
+	if name == "" {
+		name = r.URL.Query().Get("tz")
+	}
+	if name == "" {
+		return DefaultLocation, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("Неизвестный часовой пояс: %s", name)
+	}
+	return loc, nil
+}
+
+// Проверка времени задачи, пустое время допустимо.
+// Возвращает время, приведённое к ЧЧ:ММ.
+func validateTime(clock string) (string, error) {
+	if clock == "" {
+		return "", nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return "", err
+	}
+	return t.Format("15:04"), nil
+}
+
+// Функция собирает список правил repeats в одну строку repeat, так задача и хранится в БД.
+// Если переданы и repeat, и repeats, они должны совпадать.
+func joinRepeats(task *storage.Task) error {
+	if len(task.Repeats) == 0 {
+		return nil
+	}
+	joined := nd.JoinRules(task.Repeats)
+	if task.Repeat != "" && nd.JoinRules(nd.SplitRules(task.Repeat)) != joined {
+		return fmt.Errorf("Правила repeat и repeats не совпадают")
+	}
+	task.Repeat, task.Repeats = joined, nil
+	return nil
+}
+
 // Проверка на соответствие формату для поиска по дате и форматирование к 20060102
 func validateAndFormatDate(s string) (string, bool) {
 	r := regexp.MustCompile(`^\d{2}\.\d{2}\.\d{4}$`)
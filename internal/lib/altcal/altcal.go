@@ -0,0 +1,44 @@
+// Package altcal переводит даты китайского лунно-солнечного, еврейского и исламского календарей
+// в григорианские. Всё считается арифметически или по встроенной таблице, без сети.
+package altcal
+
+import "time"
+
+// Calendar календарь, в котором задача может повторяться раз в год в один и тот же день
+type Calendar interface {
+	// YearOf возвращает год календаря, на который приходится григорианский день t
+	YearOf(t time.Time) int
+	// MonthDays возвращает количество дней в месяце month года year, 0 - такого месяца нет
+	MonthDays(year, month int) int
+	// Date переводит день календаря в григорианскую дату в поясе loc.
+	// Для несуществующей даты возвращает нулевое время.
+	Date(year, month, day int, loc *time.Location) time.Time
+}
+
+// Номер дня 1970-01-01 в сквозном счёте дней от 0001-01-01 (он сам - день 1)
+const unixFixed = 719163
+
+// fixedFromTime переводит календарный день t в сквозной номер дня
+func fixedFromTime(t time.Time) int {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return int(floorDiv(day.Unix(), 86400)) + unixFixed
+}
+
+// timeFromFixed переводит сквозной номер дня в полночь этого дня в поясе loc
+func timeFromFixed(fixed int, loc *time.Location) time.Time {
+	return time.Date(1970, time.January, 1+fixed-unixFixed, 0, 0, 0, 0, loc)
+}
+
+// floorDiv деление с округлением вниз, в том числе для отрицательных
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// mod остаток, всегда неотрицательный
+func mod(a, b int) int {
+	return int(int64(a) - int64(b)*floorDiv(int64(a), int64(b)))
+}
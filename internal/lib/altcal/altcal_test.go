@@ -0,0 +1,114 @@
+package altcal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type knownDate struct {
+	year, month, day int
+	date             string
+}
+
+func checkKnown(t *testing.T, cal Calendar, tbl []knownDate) {
+	for _, v := range tbl {
+		date := cal.Date(v.year, v.month, v.day, time.UTC)
+		if v.date == "" {
+			assert.True(t, date.IsZero(), "%d-%d-%d: ожидается несуществующая дата, получено %s", v.year, v.month, v.day, date)
+			continue
+		}
+		assert.Equal(t, v.date, date.Format("20060102"), "%d-%d-%d", v.year, v.month, v.day)
+		assert.Equal(t, v.year, cal.YearOf(date), "год для %s", v.date)
+	}
+}
+
+func TestChinese(t *testing.T) {
+	checkKnown(t, Chinese, []knownDate{
+		// Новый год
+		{1900, 1, 1, "19000131"},
+		{1949, 1, 1, "19490129"},
+		{2000, 1, 1, "20000205"},
+		{2001, 1, 1, "20010124"},
+		{2008, 1, 1, "20080207"},
+		{2012, 1, 1, "20120123"},
+		{2017, 1, 1, "20170128"},
+		{2020, 1, 1, "20200125"},
+		{2021, 1, 1, "20210212"},
+		{2022, 1, 1, "20220201"},
+		{2023, 1, 1, "20230122"},
+		{2024, 1, 1, "20240210"},
+		{2025, 1, 1, "20250129"},
+		{2026, 1, 1, "20260217"},
+		{2030, 1, 1, "20300203"},
+		// Праздник фонарей, праздник драконьих лодок и праздник середины осени
+		{2024, 1, 15, "20240224"},
+		{2023, 5, 5, "20230622"},
+		{2024, 5, 5, "20240610"},
+		{2025, 5, 5, "20250531"},
+		{2020, 8, 15, "20201001"},
+		{2023, 8, 15, "20230929"},
+		{2024, 8, 15, "20240917"},
+		{2025, 8, 15, "20251006"},
+		// Вне таблицы и несуществующие дни
+		{1899, 1, 1, ""},
+		{2101, 1, 1, ""},
+		{2024, 13, 1, ""},
+		{2024, 1, 31, ""},
+	})
+}
+
+func TestHebrew(t *testing.T) {
+	checkKnown(t, Hebrew, []knownDate{
+		// Рош ха-Шана и Йом Кипур
+		{5784, Tishri, 1, "20230916"},
+		{5785, Tishri, 1, "20241003"},
+		{5785, Tishri, 10, "20241012"},
+		{5786, Tishri, 1, "20250923"},
+		// Песах, в 5784 году високосный адар
+		{5784, Nisan, 15, "20240423"},
+		{5785, Nisan, 15, "20250413"},
+		// Пурим в високосный год во втором адаре
+		{5784, Adar, 14, "20240324"},
+		{5784, AdarI, 14, "20240223"},
+		{5785, Adar, 14, "20250314"},
+		{5785, AdarI, 14, "20250314"},
+		// Ханука
+		{5785, Kislev, 25, "20241226"},
+		// 30 хешвана бывает только в длинный год
+		{5785, Heshvan, 30, "20241201"},
+		{5784, Heshvan, 30, ""},
+		{5785, 14, 1, ""},
+	})
+}
+
+func TestHijri(t *testing.T) {
+	checkKnown(t, Hijri, []knownDate{
+		{1, 1, 1, "06220719"},
+		// Рамадан, ураза-байрам и исламский новый год по таблице
+		{1445, 9, 1, "20240311"},
+		{1445, 10, 1, "20240410"},
+		// В 1445 году по таблице в зуль-хидже 30 дней, по наблюдениям новый год наступил днём раньше
+		{1445, 12, 30, "20240707"},
+		{1446, 1, 1, "20240708"},
+		{1446, 9, 1, "20250301"},
+		{1444, 12, 30, ""},
+		{1445, 13, 1, ""},
+	})
+}
+
+func TestYearOf(t *testing.T) {
+	day := func(date string) time.Time {
+		parsed, err := time.Parse("20060102", date)
+		assert.NoError(t, err)
+		return parsed
+	}
+	// Накануне нового года ещё идёт прошлый год
+	assert.Equal(t, 2023, Chinese.YearOf(day("20240209")))
+	assert.Equal(t, 2024, Chinese.YearOf(day("20240210")))
+	assert.Equal(t, 5784, Hebrew.YearOf(day("20241002")))
+	assert.Equal(t, 5785, Hebrew.YearOf(day("20241003")))
+	assert.Equal(t, 1445, Hijri.YearOf(day("20240707")))
+	assert.Equal(t, 1446, Hijri.YearOf(day("20240708")))
+}
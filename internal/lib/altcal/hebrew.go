@@ -0,0 +1,142 @@
+package altcal
+
+import (
+	"math"
+	"time"
+)
+
+// Месяцы еврейского календаря. Год начинается с тишрея, но месяцы исторически считаются от нисана.
+// Adar - тот адар, в котором Пурим: в високосный год это адар II. AdarI бывает только
+// в високосный год, в обычный он совпадает с адаром.
+const (
+	Nisan = iota + 1
+	Iyar
+	Sivan
+	Tammuz
+	Av
+	Elul
+	Tishri
+	Heshvan
+	Kislev
+	Tevet
+	Shevat
+	Adar
+	AdarI
+)
+
+// День 1 тишрея 1 года, сквозной номер дня
+const hebrewEpoch = -1373427
+
+// Hebrew еврейский календарь по правилам молада и отсрочек, без астрономии
+var Hebrew Calendar = hebrew{}
+
+type hebrew struct{}
+
+func (hebrew) YearOf(t time.Time) int {
+	fixed := fixedFromTime(t)
+	// По средней длине года 35975351/98496 дней оценка ошибается не больше чем на год
+	year := int(math.Floor(float64(fixed-hebrewEpoch) * 98496 / 35975351))
+	for hebrewNewYear(year+1) <= fixed {
+		year++
+	}
+	return year
+}
+
+func (hebrew) MonthDays(year, month int) int {
+	if month < Nisan || month > AdarI {
+		return 0
+	}
+	return hebrewMonthDays(year, hebrewMonth(year, month))
+}
+
+func (hebrew) Date(year, month, day int, loc *time.Location) time.Time {
+	if month < Nisan || month > AdarI || day < 1 {
+		return time.Time{}
+	}
+	month = hebrewMonth(year, month)
+	if day > hebrewMonthDays(year, month) {
+		return time.Time{}
+	}
+
+	fixed := hebrewNewYear(year) + day - 1
+	// Сначала месяцы от тишрея до конца года, весенние месяцы идут уже после них
+	last := hebrewLastMonth(year)
+	if month < Tishri {
+		for m := Tishri; m <= last; m++ {
+			fixed += hebrewMonthDays(year, m)
+		}
+		for m := Nisan; m < month; m++ {
+			fixed += hebrewMonthDays(year, m)
+		}
+	} else {
+		for m := Tishri; m < month; m++ {
+			fixed += hebrewMonthDays(year, m)
+		}
+	}
+	return timeFromFixed(fixed, loc)
+}
+
+// hebrewMonth переводит наш номер месяца в счёт, где 12 - адар I, а 13 - адар II
+func hebrewMonth(year, month int) int {
+	switch {
+	case month == Adar && hebrewLeap(year):
+		return 13
+	case month == AdarI:
+		return Adar
+	}
+	return month
+}
+
+// hebrewLeap проверяет, есть ли в году второй адар: 7 лет из 19
+func hebrewLeap(year int) bool {
+	return mod(7*year+1, 19) < 7
+}
+
+func hebrewLastMonth(year int) int {
+	if hebrewLeap(year) {
+		return 13
+	}
+	return 12
+}
+
+// hebrewElapsedDays дни от начала эпохи до молада тишрея года с отсрочкой на день недели
+func hebrewElapsedDays(year int) int {
+	months := int(floorDiv(int64(235*year-234), 19))
+	parts := 12084 + 13753*int64(months)
+	days := 29*months + int(floorDiv(parts, 25920))
+	// Рош ха-Шана не бывает в воскресенье, среду и пятницу
+	if mod(3*(days+1), 7) < 3 {
+		return days + 1
+	}
+	return days
+}
+
+// hebrewNewYear сквозной номер дня 1 тишрея, с отсрочками из-за длины соседних лет
+func hebrewNewYear(year int) int {
+	ny0, ny1, ny2 := hebrewElapsedDays(year-1), hebrewElapsedDays(year), hebrewElapsedDays(year+1)
+	correction := 0
+	switch {
+	case ny2-ny1 == 356:
+		correction = 2
+	case ny1-ny0 == 382:
+		correction = 1
+	}
+	return hebrewEpoch + ny1 + correction
+}
+
+// hebrewMonthDays длина месяца в счёте, где 13 - адар II
+func hebrewMonthDays(year, month int) int {
+	length := hebrewNewYear(year+1) - hebrewNewYear(year)
+	switch {
+	case month == Iyar || month == Tammuz || month == Elul || month == Tevet || month == 13:
+		return 29
+	case month == Adar && !hebrewLeap(year):
+		return 29
+	// Хешван длинный и кислев короткий, только когда весь год длинный или короткий
+	case month == Heshvan && length%10 != 5:
+		return 29
+	case month == Kislev && length%10 == 3:
+		return 29
+	}
+	return 30
+}
@@ -0,0 +1,44 @@
+package altcal
+
+import "time"
+
+// День 1 мухаррама 1 года хиджры по гражданской эпохе, 16 июля 622 года по юлианскому календарю
+const hijriEpoch = 227015
+
+// Hijri табличный исламский календарь: 30-летний цикл с 11 високосными годами.
+// Месяцы 1 - мухаррам, 9 - рамадан, 12 - зуль-хиджа. Наблюдаемое начало месяца
+// по новолунию в разных странах может отличаться на день-два.
+var Hijri Calendar = hijri{}
+
+type hijri struct{}
+
+func (hijri) YearOf(t time.Time) int {
+	fixed := fixedFromTime(t)
+	return int(floorDiv(int64(30*(fixed-hijriEpoch)+10646), 10631))
+}
+
+func (hijri) MonthDays(year, month int) int {
+	switch {
+	case month < 1 || month > 12:
+		return 0
+	case month%2 == 1:
+		return 30
+	case month == 12 && hijriLeap(year):
+		return 30
+	}
+	return 29
+}
+
+func (h hijri) Date(year, month, day int, loc *time.Location) time.Time {
+	if day < 1 || day > h.MonthDays(year, month) {
+		return time.Time{}
+	}
+	fixed := hijriEpoch - 1 + (year-1)*354 + int(floorDiv(int64(3+11*year), 30)) +
+		29*(month-1) + month/2 + day
+	return timeFromFixed(fixed, loc)
+}
+
+// hijriLeap проверяет, что в зуль-хидже года 30 дней
+func hijriLeap(year int) bool {
+	return mod(14+11*year, 30) < 11
+}
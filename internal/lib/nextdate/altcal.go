@@ -0,0 +1,165 @@
+package nextdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fedgolang/go_final_project/internal/lib/altcal"
+)
+
+// Ежегодные правила по другим календарям: день и месяц в них, дата задачи в григорианском.
+// Пишутся как "chinese 15 8", "hebrew 15 nisan" или "hijri 1 9".
+const (
+	KindChinese Kind = "chinese"
+	KindHebrew  Kind = "hebrew"
+	KindHijri   Kind = "hijri"
+)
+
+// Сколько лет вперёд ищем дату. 30-го числа может не быть несколько лет подряд,
+// а китайский календарь известен только до 2100 года.
+const altSearchYears = 100
+
+var altCalendars = map[Kind]altcal.Calendar{
+	KindChinese: altcal.Chinese,
+	KindHebrew:  altcal.Hebrew,
+	KindHijri:   altcal.Hijri,
+}
+
+// Названия месяцев еврейского календаря, индекс - номер месяца в altcal
+var (
+	hebrewMonthNames   = []string{"", "nisan", "iyar", "sivan", "tammuz", "av", "elul", "tishri", "heshvan", "kislev", "tevet", "shevat", "adar", "adar1"}
+	hebrewMonthsRu     = []string{"", "нисана", "ияра", "сивана", "таммуза", "ава", "элуля", "тишрея", "хешвана", "кислева", "тевета", "швата", "адара", "адара I"}
+	hebrewMonthsEn     = []string{"", "Nisan", "Iyar", "Sivan", "Tammuz", "Av", "Elul", "Tishri", "Heshvan", "Kislev", "Tevet", "Shevat", "Adar", "Adar I"}
+	hijriMonthsRu      = []string{"", "мухаррам", "сафар", "раби аль-авваль", "раби ас-сани", "джумада аль-уля", "джумада аль-ахира", "раджаб", "шаабан", "рамадан", "шавваль", "зуль-када", "зуль-хиджа"}
+	hijriMonthsEn      = []string{"", "Muharram", "Safar", "Rabi al-Awwal", "Rabi al-Thani", "Jumada al-Awwal", "Jumada al-Thani", "Rajab", "Shaban", "Ramadan", "Shawwal", "Dhu al-Qadah", "Dhu al-Hijjah"}
+	altCalendarNamesRu = map[Kind]string{KindChinese: "китайскому", KindHebrew: "еврейскому", KindHijri: "исламскому"}
+	altCalendarNamesEn = map[Kind]string{KindChinese: "Chinese", KindHebrew: "Hebrew", KindHijri: "Hijri"}
+)
+
+// parseAltCalendar разбирает день и месяц правил chinese, hebrew и hijri
+func parseAltCalendar(repeat string, kind Kind, args []string) (Rule, error) {
+	if len(args) != 2 {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: fmt.Sprintf("правило %s принимает день и месяц", kind)}
+	}
+	day, err := strconv.Atoi(args[0])
+	if err != nil || day < 1 || day > 30 {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("день месяца вне диапазона 1..30: %s", args[0])}
+	}
+	month, err := parseAltMonth(kind, args[1])
+	if err != nil {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: err.Error()}
+	}
+	return Rule{Kind: kind, MonthDays: []int{day}, Months: []int{month}}, nil
+}
+
+// parseAltMonth разбирает месяц: номер, а для еврейского календаря ещё и название
+func parseAltMonth(kind Kind, value string) (int, error) {
+	last := 12
+	if kind == KindHebrew {
+		last = altcal.AdarI
+		for i, name := range hebrewMonthNames {
+			if name != "" && strings.EqualFold(value, name) {
+				return i, nil
+			}
+		}
+		// adar2 всегда тот адар, в котором Пурим
+		if strings.EqualFold(value, "adar2") {
+			return altcal.Adar, nil
+		}
+	}
+	month, err := strconv.Atoi(value)
+	if err != nil || month < 1 || month > last {
+		return 0, fmt.Errorf("месяц вне диапазона 1..%d: %s", last, value)
+	}
+	return month, nil
+}
+
+// altMaxDays сколько дней месяц бывает в самый длинный год
+func altMaxDays(kind Kind, month int) int {
+	switch {
+	case kind == KindHijri && month%2 == 0 && month != 12:
+		return 29
+	case kind == KindHebrew && (month == altcal.Iyar || month == altcal.Tammuz || month == altcal.Elul || month == altcal.Tevet):
+		return 29
+	}
+	return 30
+}
+
+// altOccurrence ищет ближайшую дату правила строго после max(now, date)
+func (r Rule) altOccurrence(now, date time.Time) time.Time {
+	after := now
+	if date.After(now) {
+		after = date
+	}
+
+	cal := altCalendars[r.Kind]
+	start := cal.YearOf(after)
+	for year := start; year <= start+altSearchYears; year++ {
+		next := r.altDate(cal, year, after.Location())
+		if !next.IsZero() && next.After(after) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// altDate переводит день правила в году year в григорианскую дату с учётом политики конца месяца
+func (r Rule) altDate(cal altcal.Calendar, year int, loc *time.Location) time.Time {
+	day, month := r.MonthDays[0], r.Months[0]
+	last := cal.MonthDays(year, month)
+	switch {
+	case last == 0:
+		return time.Time{}
+	case day <= last:
+		return cal.Date(year, month, day, loc)
+	}
+
+	switch r.monthEnd() {
+	case MonthEndClamp:
+		return cal.Date(year, month, last, loc)
+	case MonthEndOverflow:
+		return cal.Date(year, month, last, loc).AddDate(0, 0, day-last)
+	}
+	return time.Time{}
+}
+
+// altBase возвращает запись правила, месяцы еврейского календаря названием
+func (r Rule) altBase() string {
+	month := strconv.Itoa(r.Months[0])
+	if r.Kind == KindHebrew {
+		month = hebrewMonthNames[r.Months[0]]
+	}
+	return fmt.Sprintf("%s %d %s", r.Kind, r.MonthDays[0], month)
+}
+
+// altDescribeRu описание на русском: "каждый год 15 нисана по еврейскому календарю"
+func (r Rule) altDescribeRu() string {
+	day, month := r.MonthDays[0], r.Months[0]
+	var date string
+	switch r.Kind {
+	case KindHebrew:
+		date = fmt.Sprintf("%d %s", day, hebrewMonthsRu[month])
+	case KindHijri:
+		date = fmt.Sprintf("%d-го числа месяца %s", day, hijriMonthsRu[month])
+	default:
+		date = fmt.Sprintf("%d-го числа %d-го месяца", day, month)
+	}
+	return "каждый год " + date + " по " + altCalendarNamesRu[r.Kind] + " календарю"
+}
+
+// altDescribeEn описание на английском: "every year on 15 Nisan of the Hebrew calendar"
+func (r Rule) altDescribeEn() string {
+	day, month := r.MonthDays[0], r.Months[0]
+	var date string
+	switch r.Kind {
+	case KindHebrew:
+		date = fmt.Sprintf("%d %s", day, hebrewMonthsEn[month])
+	case KindHijri:
+		date = fmt.Sprintf("%d %s", day, hijriMonthsEn[month])
+	default:
+		date = fmt.Sprintf("day %d of month %d", day, month)
+	}
+	return "every year on " + date + " of the " + altCalendarNamesEn[r.Kind] + " calendar"
+}
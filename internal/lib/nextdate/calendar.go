@@ -0,0 +1,193 @@
+package nextdate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Политики переноса даты, выпавшей на выходной или праздник
+const (
+	RollNext    = "next"    // На следующий рабочий день
+	RollPrev    = "prev"    // На предыдущий рабочий день
+	RollNearest = "nearest" // На ближайший рабочий день, при равенстве вперёд
+)
+
+// Сколько дней максимум ищем рабочий день, дальше считаем календарь сломанным
+const maxRollDays = 366
+
+// Calendar производственный календарь: выходные суббота и воскресенье плюс праздники
+type Calendar struct {
+	holidays map[string]bool // Ключ - дата в формате 20060102
+}
+
+var (
+	calendarMu sync.RWMutex
+	calendar   = &Calendar{}
+)
+
+// SetCalendar задаёт календарь, по которому считаются рабочие дни.
+// nil возвращает календарь без праздников, только с выходными.
+func SetCalendar(c *Calendar) {
+	if c == nil {
+		c = &Calendar{}
+	}
+	calendarMu.Lock()
+	calendar = c
+	calendarMu.Unlock()
+}
+
+func currentCalendar() *Calendar {
+	calendarMu.RLock()
+	defer calendarMu.RUnlock()
+	return calendar
+}
+
+// NewCalendar собирает календарь из списка праздников в формате 20060102
+func NewCalendar(holidays ...string) (*Calendar, error) {
+	c := &Calendar{holidays: map[string]bool{}}
+	for _, day := range holidays {
+		if _, err := time.Parse("20060102", day); err != nil {
+			return nil, fmt.Errorf("некорректная дата праздника: %s", day)
+		}
+		c.holidays[day] = true
+	}
+	return c, nil
+}
+
+// LoadCalendar читает праздники из файла.
+// Поддерживается простой список (одна дата ГГГГММДД в строке, # - комментарий) и ICS.
+func LoadCalendar(path string) (*Calendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл праздников: %s", err)
+	}
+	defer file.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл праздников: %s", err)
+	}
+
+	for _, line := range lines {
+		if line == "BEGIN:VCALENDAR" {
+			return parseICS(lines)
+		}
+	}
+
+	holidays := []string{}
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		holidays = append(holidays, line)
+	}
+	return NewCalendar(holidays...)
+}
+
+// parseICS достаёт даты событий из ICS, многодневные события разворачиваются по дням
+func parseICS(lines []string) (*Calendar, error) {
+	c := &Calendar{holidays: map[string]bool{}}
+
+	var start, end time.Time
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Параметры свойства вроде ;VALUE=DATE нас не интересуют
+		name, _, _ = strings.Cut(name, ";")
+
+		switch name {
+		case "BEGIN":
+			if value == "VEVENT" {
+				start, end = time.Time{}, time.Time{}
+			}
+		case "DTSTART", "DTEND":
+			if len(value) < 8 {
+				return nil, fmt.Errorf("некорректная дата в ICS: %s", line)
+			}
+			day, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("некорректная дата в ICS: %s", line)
+			}
+			if name == "DTSTART" {
+				start = day
+			} else {
+				end = day
+			}
+		case "END":
+			if value != "VEVENT" || start.IsZero() {
+				continue
+			}
+			// DTEND по стандарту не включается, без него событие однодневное
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+				c.holidays[day.Format("20060102")] = true
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// IsWorkday проверяет, рабочий ли день
+func (c *Calendar) IsWorkday(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[date.Format("20060102")]
+}
+
+// Roll переносит дату на рабочий день по политике. Рабочий день не меняется.
+func (c *Calendar) Roll(date time.Time, policy string) time.Time {
+	if c.IsWorkday(date) {
+		return date
+	}
+
+	for i := 1; i <= maxRollDays; i++ {
+		next, prev := date.AddDate(0, 0, i), date.AddDate(0, 0, -i)
+		switch policy {
+		case RollNext:
+			if c.IsWorkday(next) {
+				return next
+			}
+		case RollPrev:
+			if c.IsWorkday(prev) {
+				return prev
+			}
+		case RollNearest:
+			if c.IsWorkday(next) {
+				return next
+			}
+			if c.IsWorkday(prev) {
+				return prev
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// AddWorkdays прибавляет к дате n рабочих дней
+func (c *Calendar) AddWorkdays(date time.Time, n int) time.Time {
+	for i := 0; i < maxRollDays*n; i++ {
+		date = date.AddDate(0, 0, 1)
+		if c.IsWorkday(date) {
+			n--
+			if n == 0 {
+				return date
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,383 @@
+package nextdate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Правило в формате cron из пяти полей: минуты, часы, день месяца, месяц, день недели.
+// Пишется как "cron 0 9 * * 1-5" или просто "0 9 * * 1-5".
+const KindCron Kind = "cron"
+
+// Сколько дней вперёд ищем дату. Самое редкое, что бывает, это 29 февраля,
+// между ними до 8 лет, если на пути невисокосный 2100 год
+const cronHorizonDays = 9 * 366
+
+// Сокращения вместо пяти полей
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Названия месяцев и дней недели, в cron воскресенье это 0
+var (
+	cronMonthNames   = []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	cronWeekdayNames = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// Cron разобранное cron-выражение, в каждом поле полный список подходящих значений.
+// Как в классическом cron, если поле дня месяца или дня недели начинается с *, день должен подходить
+// под оба поля, иначе достаточно любого из них: "0 0 13 * 5" - 13-е или пятница, "0 0 */2 * 1" - нечётные понедельники.
+type Cron struct {
+	Minutes  []int // 0..59
+	Hours    []int // 0..23
+	Days     []int // 1..31
+	Months   []int // 1..12
+	Weekdays []int // 1 - понедельник, 7 - воскресенье, как и в правиле w
+
+	dayStar     bool // Поле дня месяца начиналось с *
+	weekdayStar bool // Поле дня недели начиналось с *
+}
+
+// isCron проверяет, похоже ли первое слово правила на начало cron-выражения
+func isCron(field string) bool {
+	if field == string(KindCron) {
+		return true
+	}
+	if _, ok := cronMacros[strings.ToLower(field)]; ok {
+		return true
+	}
+	return field != "" && (field[0] == '*' || (field[0] >= '0' && field[0] <= '9'))
+}
+
+// parseCron разбирает пять полей cron или одно сокращение вроде @daily
+func parseCron(fields []string) (*Cron, error) {
+	if len(fields) == 1 {
+		if macro, ok := cronMacros[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(macro)
+		}
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("в cron-выражении должно быть 5 полей, передано %d", len(fields))
+	}
+
+	c := &Cron{}
+	var err error
+	if c.Minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("минуты: %s", err)
+	}
+	if c.Hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("часы: %s", err)
+	}
+	if c.Days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("день месяца: %s", err)
+	}
+	if c.Months, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("месяц: %s", err)
+	}
+	weekdays, err := parseCronField(fields[4], 0, 7, cronWeekdayNames)
+	if err != nil {
+		return nil, fmt.Errorf("день недели: %s", err)
+	}
+	// 0 и 7 в cron одинаково воскресенье, у нас оно 7
+	for _, day := range weekdays {
+		if day == 0 {
+			day = 7
+		}
+		if !search(day, c.Weekdays) {
+			c.Weekdays = append(c.Weekdays, day)
+		}
+	}
+	sort.Ints(c.Weekdays)
+
+	c.dayStar, c.weekdayStar = strings.HasPrefix(fields[2], "*"), strings.HasPrefix(fields[4], "*")
+	// "0 0 1-31 * 1" в классическом cron срабатывает каждый день: полное поле через "или" подходит всегда.
+	// Записываем такое выражение звёздочками, дальше его можно не отличать от "0 0 * * *"
+	if c.either() && (c.anyDay() || c.anyWeekday()) {
+		c.Days, c.Weekdays = fullRange(1, 31), fullRange(1, 7)
+		c.dayStar, c.weekdayStar = true, true
+	}
+
+	return c, nil
+}
+
+// fullRange возвращает все значения от from до to
+func fullRange(from, to int) []int {
+	values := make([]int, 0, to-from+1)
+	for v := from; v <= to; v++ {
+		values = append(values, v)
+	}
+	return values
+}
+
+// parseCronField разбирает одно поле: *, 5, 1-5, */15, 10-20/5, MON-FRI и их списки через запятую.
+// names - необязательные названия значений, индекс в слайсе это само значение.
+func parseCronField(field string, min, max int, names []string) ([]int, error) {
+	values := []int{}
+	for _, item := range strings.Split(field, ",") {
+		base, stepValue, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepValue)
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("некорректный шаг %q", item)
+			}
+		}
+
+		from, to := min, max
+		if base != "*" {
+			low, high, isRange := strings.Cut(base, "-")
+			var err error
+			if from, err = cronValue(low, min, max, names); err != nil {
+				return nil, err
+			}
+			to = from
+			if isRange {
+				if to, err = cronValue(high, min, max, names); err != nil {
+					return nil, err
+				}
+				if to < from {
+					return nil, fmt.Errorf("диапазон %q задан в обратном порядке", base)
+				}
+			} else if hasStep {
+				// 5/15 значит с 5 до конца с шагом 15
+				to = max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			if !search(v, values) {
+				values = append(values, v)
+			}
+		}
+	}
+	sort.Ints(values)
+	return values, nil
+}
+
+// cronValue разбирает одно значение поля, числом или названием
+func cronValue(value string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(value, name) {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q не число", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("%d вне диапазона %d..%d", n, min, max)
+	}
+	return n, nil
+}
+
+// either проверяет, что день подходит по любому из полей дня месяца и дня недели, а не по обоим
+func (c *Cron) either() bool { return !c.dayStar && !c.weekdayStar }
+
+// anyDay и anyWeekday проверяют, что поле дня не ограничено
+func (c *Cron) anyDay() bool     { return len(c.Days) == 31 }
+func (c *Cron) anyWeekday() bool { return len(c.Weekdays) == 7 }
+func (c *Cron) anyMonth() bool   { return len(c.Months) == 12 }
+
+// matchDay проверяет, подходит ли выражению календарный день
+func (c *Cron) matchDay(day time.Time) bool {
+	if !search(int(day.Month()), c.Months) {
+		return false
+	}
+	dom := search(day.Day(), c.Days)
+	dow := search(isoWeekday(day), c.Weekdays)
+	if c.either() {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// fires проверяет, что по выражению бывает хоть одна дата: 30 февраля не бывает.
+// День недели с любым существующим днём месяца рано или поздно совпадает.
+func (c *Cron) fires() bool {
+	if c.either() {
+		return true
+	}
+	return monthDaysExist(c.Days, c.Months)
+}
+
+// Next ищет первый момент строго после after, секунды не учитываются
+func (c *Cron) Next(after time.Time) time.Time {
+	day := truncateDay(after)
+	for i := 0; i <= cronHorizonDays; i++ {
+		cur := day.AddDate(0, 0, i)
+		if !c.matchDay(cur) {
+			continue
+		}
+		for _, hour := range c.Hours {
+			for _, minute := range c.Minutes {
+				next := time.Date(cur.Year(), cur.Month(), cur.Day(), hour, minute, 0, 0, cur.Location())
+				if next.After(after) {
+					return next
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// String собирает каноничную запись: полные поля звёздочкой, подряд идущие значения диапазоном.
+// Ограниченные поля дня, которые начинались с *, так и начинаются, чтобы не поменялся смысл.
+func (c *Cron) String() string {
+	days, weekdays := cronField(c.Days, 31), cronField(c.Weekdays, 7)
+	if c.dayStar && !c.anyDay() {
+		days = cronStarField(c.Days, 1, 31)
+	}
+	if c.weekdayStar && !c.anyWeekday() {
+		// Поле с * всегда содержит 0, воскресенье, поэтому считаем в нумерации cron
+		cronWeekdays := []int{}
+		for _, day := range c.Weekdays {
+			cronWeekdays = append(cronWeekdays, day%7)
+		}
+		sort.Ints(cronWeekdays)
+		weekdays = cronStarField(cronWeekdays, 0, 6)
+	}
+	return strings.Join([]string{
+		cronField(c.Minutes, 60),
+		cronField(c.Hours, 24),
+		days,
+		cronField(c.Months, 12),
+		weekdays,
+	}, " ")
+}
+
+// cronField записывает поле, size - сколько всего значений бывает у поля
+func cronField(values []int, size int) string {
+	if len(values) == size {
+		return "*"
+	}
+	var items []string
+	for i := 0; i < len(values); {
+		j := i
+		for j+1 < len(values) && values[j+1] == values[j]+1 {
+			j++
+		}
+		// Два соседних значения короче записать списком
+		if j-i >= 2 {
+			items = append(items, fmt.Sprintf("%d-%d", values[i], values[j]))
+		} else {
+			for k := i; k <= j; k++ {
+				items = append(items, strconv.Itoa(values[k]))
+			}
+		}
+		i = j + 1
+	}
+	return strings.Join(items, ",")
+}
+
+// cronStarField записывает поле, начинавшееся с *: наименьший шаг от min, значения которого все есть в поле,
+// и остальные значения списком. values отсортированы и содержат min.
+func cronStarField(values []int, min, max int) string {
+	step := max - min + 1
+	for s := 2; s <= max-min; s++ {
+		all := true
+		for v := min; v <= max && all; v += s {
+			all = search(v, values)
+		}
+		if all {
+			step = s
+			break
+		}
+	}
+
+	items := []string{fmt.Sprintf("*/%d", step)}
+	for _, v := range values {
+		if (v-min)%step != 0 {
+			items = append(items, strconv.Itoa(v))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// clock возвращает время ЧЧ:ММ, если выражение срабатывает раз в сутки
+func (c *Cron) clock() (string, bool) {
+	if len(c.Hours) != 1 || len(c.Minutes) != 1 {
+		return "", false
+	}
+	return fmt.Sprintf("%02d:%02d", c.Hours[0], c.Minutes[0]), true
+}
+
+// native переводит выражение в правило d, w или m, если точный эквивалент есть
+func (c *Cron) native() (Rule, bool) {
+	at, ok := c.clock()
+	if !ok {
+		return Rule{}, false
+	}
+
+	switch {
+	case c.anyDay() && c.anyWeekday() && c.anyMonth():
+		return Rule{Kind: KindDay, Interval: 1, At: at}, true
+	case c.anyDay() && c.anyMonth():
+		return Rule{Kind: KindWeek, Weekdays: c.Weekdays, At: at}, true
+	case c.anyWeekday():
+		rule := Rule{Kind: KindMonth, MonthDays: c.Days, At: at}
+		if !c.anyMonth() {
+			rule.Months = c.Months
+		}
+		// cron несуществующие даты пропускает, а политика по умолчанию могла поменяться
+		if rule.monthEnd() != MonthEndSkip {
+			rule.MonthEnd = MonthEndSkip
+		}
+		return rule, true
+	}
+	return Rule{}, false
+}
+
+// ToCron переводит правило в cron-выражение, если точный эквивалент есть.
+// Правило без времени срабатывает в полночь.
+func (r Rule) ToCron() (string, error) {
+	if r.Kind == KindUnion {
+		return "", errUnion("cron")
+	}
+	// Условий окончания, переносов и отсчёта от выполнения в cron нет
+	if r.Roll != "" || r.Workdays || r.AfterDone {
+		return "", fmt.Errorf("правило с переносами или от момента выполнения нельзя перевести в cron")
+	}
+	if r.Count > 0 || !r.Until.IsZero() {
+		return "", fmt.Errorf("правило с until или count нельзя перевести в cron")
+	}
+
+	if r.Kind == KindCron {
+		return r.Cron.String(), nil
+	}
+	native, ok := r.Native()
+	if !ok {
+		return "", fmt.Errorf("правило %s нельзя перевести в cron", r.Kind)
+	}
+
+	clock := "00:00"
+	if native.At != "" {
+		clock = native.At
+	}
+	t, _ := time.Parse("15:04", clock)
+	prefix := fmt.Sprintf("%d %d", t.Minute(), t.Hour())
+
+	switch {
+	case native.Kind == KindDay && native.Interval == 1:
+		return prefix + " * * *", nil
+	case native.Kind == KindWeek && native.Interval <= 1:
+		return fmt.Sprintf("%s * * %s", prefix, cronField(native.Weekdays, 7)), nil
+	case native.Kind == KindMonth && native.Interval <= 1 && native.monthEnd() == MonthEndSkip && native.MonthDays[len(native.MonthDays)-1] > 0:
+		months := "*"
+		if len(native.Months) > 0 {
+			months = cronField(native.Months, 12)
+		}
+		return fmt.Sprintf("%s %s %s *", prefix, cronField(native.MonthDays, 31), months), nil
+	}
+	return "", fmt.Errorf("правило %s нельзя перевести в cron", native.base())
+}
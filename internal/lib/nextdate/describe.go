@@ -0,0 +1,672 @@
+package nextdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Языки описаний правил
+const (
+	LangRu = "ru"
+	LangEn = "en"
+)
+
+// Названия дней недели и месяцев в нужных падежах, индекс 0 не используется
+var (
+	ruWeekdaysDative      = []string{"", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам", "воскресеньям"}
+	ruWeekdaysAccusative  = []string{"", "понедельник", "вторник", "среду", "четверг", "пятницу", "субботу", "воскресенье"}
+	ruMonthsGenitive      = []string{"", "января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+	ruMonthsPrepositional = []string{"", "январе", "феврале", "марте", "апреле", "мае", "июне", "июле", "августе", "сентябре", "октябре", "ноябре", "декабре"}
+
+	enWeekdays = []string{"", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	enMonths   = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+)
+
+// Род дней недели для порядковых числительных: 0 - мужской, 1 - женский, 2 - средний
+var ruWeekdayGender = []int{0, 0, 0, 1, 0, 1, 1, 2}
+
+// Порядковые числительные в винительном падеже по родам
+var ruOrdinals = [][]string{
+	{"первый", "первую", "первое"},
+	{"второй", "вторую", "второе"},
+	{"третий", "третью", "третье"},
+	{"четвёртый", "четвёртую", "четвёртое"},
+	{"пятый", "пятую", "пятое"},
+}
+
+var enOrdinals = []string{"first", "second", "third", "fourth", "fifth"}
+
+// Describe возвращает описание правила repeat человеческим языком.
+// lang - LangRu или LangEn, пустой язык считается русским.
+func Describe(repeat, lang string) (string, error) {
+	rule, err := ParseCached(repeat)
+	if err != nil {
+		return "", err
+	}
+	return rule.Describe(lang)
+}
+
+// Describe то же, что и одноимённая функция, но для уже разобранного правила
+func (r Rule) Describe(lang string) (string, error) {
+	if r.Kind == KindUnion {
+		return r.describeUnion(lang)
+	}
+	switch lang {
+	case "", LangRu:
+		return r.describeRu(), nil
+	case LangEn:
+		return r.describeEn(), nil
+	}
+	return "", fmt.Errorf("неизвестный язык описания: %s", lang)
+}
+
+// describeRu описание на русском
+func (r Rule) describeRu() string {
+	var text string
+	switch r.Kind {
+	case KindDay:
+		if r.Workdays {
+			text = ruEvery(r.Interval, "каждый", "рабочий день", "рабочих дня", "рабочих дней")
+		} else {
+			text = ruEvery(r.Interval, "каждый", "день", "дня", "дней")
+		}
+	case KindHour:
+		text = ruEvery(r.Interval, "каждый", "час", "часа", "часов")
+	case KindYear:
+		if len(r.YearDates) > 0 || len(r.Weeks) > 0 {
+			text = r.yearDescribeRu()
+			break
+		}
+		text = ruEvery(max(r.Interval, 1), "каждый", "год", "года", "лет") + " в день даты задачи"
+	case KindChinese, KindHebrew, KindHijri:
+		text = r.altDescribeRu()
+	case KindScript:
+		text = "по правилу-скрипту " + r.Script
+	case KindWeek:
+		text = ruWeekdays(r.Weekdays)
+		if r.Interval > 1 {
+			text = ruEvery(r.Interval, "каждую", "неделю", "недели", "недель") + ", " + text
+		}
+	case KindMonth:
+		if r.Interval > 1 {
+			text = ruMonthDays(r.MonthDays) + " " + ruEvery(r.Interval, "каждый", "месяц", "месяца", "месяцев")
+		} else {
+			text = ruMonthDays(r.MonthDays) + " " + ruMonthsOf(r.Months)
+		}
+	case KindMonthWeekday:
+		text = ruNthWeekdays(r.NthWeekdays) + " " + ruMonthsOf(r.Months)
+	case KindRRule:
+		if native, ok := r.Native(); ok {
+			return native.describeRu()
+		}
+		text = r.RRule.describeRu()
+	case KindSpaced:
+		steps := make([]string, 0, len(r.Steps))
+		for _, step := range r.Steps {
+			steps = append(steps, strconv.Itoa(step))
+		}
+		last := r.Steps[len(r.Steps)-1]
+		text = "интервальное повторение: через " + joinWords(steps, "и") + " " + ruPlural(last, "день", "дня", "дней") +
+			", дальше интервал растёт с каждым выполнением"
+	case KindCron:
+		if native, ok := r.Native(); ok {
+			return native.describeRu()
+		}
+		text = r.Cron.describeRu()
+	}
+
+	if r.At != "" {
+		text += " в " + r.At
+	}
+	if r.AfterDone {
+		text += ", считая от последнего выполнения"
+	}
+	text += r.monthEndRu()
+	switch r.Roll {
+	case RollNext:
+		text += ", выходные и праздники переносятся на следующий рабочий день"
+	case RollPrev:
+		text += ", выходные и праздники переносятся на предыдущий рабочий день"
+	case RollNearest:
+		text += ", выходные и праздники переносятся на ближайший рабочий день"
+	}
+	if !r.Until.IsZero() {
+		text += ", до " + r.Until.Format("02.01.2006") + " включительно"
+	}
+	if r.Count > 0 {
+		text += fmt.Sprintf(", всего %d %s", r.Count, ruPlural(r.Count, "раз", "раза", "раз"))
+	}
+	return text
+}
+
+// describeEn описание на английском
+func (r Rule) describeEn() string {
+	var text string
+	switch r.Kind {
+	case KindDay:
+		if r.Workdays {
+			text = enEvery(r.Interval, "working day", "working days")
+		} else {
+			text = enEvery(r.Interval, "day", "days")
+		}
+	case KindHour:
+		text = enEvery(r.Interval, "hour", "hours")
+	case KindYear:
+		if len(r.YearDates) > 0 || len(r.Weeks) > 0 {
+			text = r.yearDescribeEn()
+			break
+		}
+		text = enEvery(max(r.Interval, 1), "year", "years") + " on the task date"
+	case KindChinese, KindHebrew, KindHijri:
+		text = r.altDescribeEn()
+	case KindScript:
+		text = "by the script rule " + r.Script
+	case KindWeek:
+		days := make([]string, 0, len(r.Weekdays))
+		for _, day := range r.Weekdays {
+			days = append(days, enWeekdays[day])
+		}
+		text = "every " + joinWords(days, "and")
+		if r.Interval > 1 {
+			text = enEvery(r.Interval, "week", "weeks") + " on " + joinWords(days, "and")
+		}
+	case KindMonth:
+		if r.Interval > 1 {
+			text = "on the " + enMonthDays(r.MonthDays) + " " + enEvery(r.Interval, "month", "months")
+		} else {
+			text = "on the " + enMonthDays(r.MonthDays) + " " + enMonthsOf(r.Months)
+		}
+	case KindMonthWeekday:
+		text = "on the " + enNthWeekdays(r.NthWeekdays) + " " + enMonthsOf(r.Months)
+	case KindRRule:
+		if native, ok := r.Native(); ok {
+			return native.describeEn()
+		}
+		text = r.RRule.describeEn()
+	case KindSpaced:
+		steps := make([]string, 0, len(r.Steps))
+		for _, step := range r.Steps {
+			steps = append(steps, strconv.Itoa(step))
+		}
+		unit := "days"
+		if r.Steps[len(r.Steps)-1] == 1 {
+			unit = "day"
+		}
+		text = "spaced repetition: after " + joinWords(steps, "and") + " " + unit +
+			", then the interval keeps growing with each completion"
+	case KindCron:
+		if native, ok := r.Native(); ok {
+			return native.describeEn()
+		}
+		text = r.Cron.describeEn()
+	}
+
+	if r.At != "" {
+		text += " at " + r.At
+	}
+	if r.AfterDone {
+		text += ", counting from the last completion"
+	}
+	text += r.monthEndEn()
+	switch r.Roll {
+	case RollNext:
+		text += ", moved to the next working day when it falls on a day off"
+	case RollPrev:
+		text += ", moved to the previous working day when it falls on a day off"
+	case RollNearest:
+		text += ", moved to the nearest working day when it falls on a day off"
+	}
+	if !r.Until.IsZero() {
+		text += ", until " + r.Until.Format("2006-01-02")
+	}
+	if r.Count == 1 {
+		text += ", once"
+	} else if r.Count > 1 {
+		text += fmt.Sprintf(", %d times", r.Count)
+	}
+	return text
+}
+
+// monthEndRu описание явно заданной политики конца месяца
+func (r Rule) monthEndRu() string {
+	if r.Kind == KindDay {
+		switch r.MonthEnd {
+		case MonthEndOverflow:
+			return ", 29 февраля считается обычным днём"
+		case MonthEndClamp:
+			return ", повторение 29 февраля переносится на 28-е"
+		case MonthEndSkip:
+			return ", повторение 29 февраля пропускается"
+		}
+		return ""
+	}
+	switch r.MonthEnd {
+	case MonthEndOverflow:
+		return ", если такого дня в месяце нет, повторение переходит на следующий месяц"
+	case MonthEndClamp:
+		return ", если такого дня в месяце нет, берётся последний день месяца"
+	case MonthEndSkip:
+		return ", если такого дня в месяце нет, повторение пропускается"
+	}
+	return ""
+}
+
+// monthEndEn описание явно заданной политики конца месяца на английском
+func (r Rule) monthEndEn() string {
+	if r.Kind == KindDay {
+		switch r.MonthEnd {
+		case MonthEndOverflow:
+			return ", February 29 is an ordinary day"
+		case MonthEndClamp:
+			return ", an occurrence on February 29 moves to the 28th"
+		case MonthEndSkip:
+			return ", an occurrence on February 29 is skipped"
+		}
+		return ""
+	}
+	switch r.MonthEnd {
+	case MonthEndOverflow:
+		return ", a missing day rolls over into the next month"
+	case MonthEndClamp:
+		return ", a missing day becomes the last day of the month"
+	case MonthEndSkip:
+		return ", a missing day is skipped"
+	}
+	return ""
+}
+
+// describeRu описание RRULE, у которого нет эквивалента среди наших правил
+func (rr *RRule) describeRu() string {
+	var parts []string
+	switch rr.Freq {
+	case FreqDaily:
+		parts = append(parts, ruEvery(rr.Interval, "каждый", "день", "дня", "дней"))
+	case FreqWeekly:
+		parts = append(parts, ruEvery(rr.Interval, "каждую", "неделю", "недели", "недель"))
+	case FreqMonthly:
+		parts = append(parts, ruEvery(rr.Interval, "каждый", "месяц", "месяца", "месяцев"))
+	case FreqYearly:
+		parts = append(parts, ruEvery(rr.Interval, "каждый", "год", "года", "лет"))
+	}
+
+	if len(rr.ByMonth) > 0 {
+		months := make([]string, 0, len(rr.ByMonth))
+		for _, month := range rr.ByMonth {
+			months = append(months, ruMonthsPrepositional[month])
+		}
+		parts = append(parts, "в "+joinWords(months, "и"))
+	}
+	if len(rr.ByMonthDay) > 0 {
+		parts = append(parts, ruMonthDays(rr.ByMonthDay))
+	}
+	if len(rr.ByDay) > 0 {
+		if rr.ByDay[0].N == 0 {
+			days := make([]int, 0, len(rr.ByDay))
+			for _, wn := range rr.ByDay {
+				days = append(days, wn.Weekday)
+			}
+			parts = append(parts, ruWeekdays(days))
+		} else {
+			parts = append(parts, ruNthWeekdays(rr.ByDay))
+		}
+	}
+	if len(rr.BySetPos) > 0 {
+		positions := make([]string, 0, len(rr.BySetPos))
+		for _, pos := range rr.BySetPos {
+			positions = append(positions, ruPosition(pos))
+		}
+		parts = append(parts, "из подходящих дат берётся "+joinWords(positions, "и"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeEn описание RRULE на английском
+func (rr *RRule) describeEn() string {
+	var parts []string
+	switch rr.Freq {
+	case FreqDaily:
+		parts = append(parts, enEvery(rr.Interval, "day", "days"))
+	case FreqWeekly:
+		parts = append(parts, enEvery(rr.Interval, "week", "weeks"))
+	case FreqMonthly:
+		parts = append(parts, enEvery(rr.Interval, "month", "months"))
+	case FreqYearly:
+		parts = append(parts, enEvery(rr.Interval, "year", "years"))
+	}
+
+	if len(rr.ByMonth) > 0 {
+		months := make([]string, 0, len(rr.ByMonth))
+		for _, month := range rr.ByMonth {
+			months = append(months, enMonths[month])
+		}
+		parts = append(parts, "in "+joinWords(months, "and"))
+	}
+	if len(rr.ByMonthDay) > 0 {
+		parts = append(parts, "on the "+enMonthDays(rr.ByMonthDay))
+	}
+	if len(rr.ByDay) > 0 {
+		if rr.ByDay[0].N == 0 {
+			days := make([]string, 0, len(rr.ByDay))
+			for _, wn := range rr.ByDay {
+				days = append(days, enWeekdays[wn.Weekday])
+			}
+			parts = append(parts, "on "+joinWords(days, "and"))
+		} else {
+			parts = append(parts, "on the "+enNthWeekdays(rr.ByDay))
+		}
+	}
+	if len(rr.BySetPos) > 0 {
+		positions := make([]string, 0, len(rr.BySetPos))
+		for _, pos := range rr.BySetPos {
+			positions = append(positions, enOrdinal(pos))
+		}
+		parts = append(parts, "keeping only the "+joinWords(positions, "and")+" of the matching dates")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeRu описание cron-выражения, у которого нет эквивалента среди наших правил
+func (c *Cron) describeRu() string {
+	var days string
+	switch {
+	case c.anyDay() && c.anyWeekday():
+		days = "каждый день"
+	case c.anyDay():
+		days = ruWeekdays(c.Weekdays)
+	case c.anyWeekday():
+		days = ruMonthDays(c.Days)
+	case c.either():
+		days = ruMonthDays(c.Days) + " и " + ruWeekdays(c.Weekdays)
+	default:
+		names := make([]string, 0, len(c.Weekdays))
+		for _, day := range c.Weekdays {
+			names = append(names, ruWeekdaysAccusative[day])
+		}
+		days = ruMonthDays(c.Days) + ", если число приходится на " + joinWords(names, "или")
+	}
+	if !c.anyMonth() {
+		names := make([]string, 0, len(c.Months))
+		for _, month := range c.Months {
+			names = append(names, ruMonthsPrepositional[month])
+		}
+		days += " в " + joinWords(names, "и")
+	}
+
+	var clock string
+	switch {
+	case len(c.Hours)*len(c.Minutes) <= cronDescribeTimes:
+		clock = "в " + joinWords(c.times(), "и")
+	case len(c.Hours) == 24 && len(c.Minutes) == 60:
+		clock = "каждую минуту"
+	case len(c.Hours) == 24:
+		clock = "каждый час в минуты " + cronField(c.Minutes, 60)
+	case len(c.Minutes) == 60:
+		clock = "каждую минуту в часы " + cronField(c.Hours, 24)
+	default:
+		clock = "в часы " + cronField(c.Hours, 24) + " в минуты " + cronField(c.Minutes, 60)
+	}
+	return days + " " + clock
+}
+
+// describeEn описание cron-выражения на английском
+func (c *Cron) describeEn() string {
+	var days string
+	switch {
+	case c.anyDay() && c.anyWeekday():
+		days = "every day"
+	case c.anyDay():
+		days = "every " + enWeekdayList(c.Weekdays)
+	case c.anyWeekday():
+		days = "on the " + enMonthDays(c.Days)
+	case c.either():
+		days = "on the " + enMonthDays(c.Days) + " and every " + enWeekdayList(c.Weekdays)
+	default:
+		names := make([]string, 0, len(c.Weekdays))
+		for _, day := range c.Weekdays {
+			names = append(names, enWeekdays[day])
+		}
+		days = "on the " + enMonthDays(c.Days) + " if it falls on a " + joinWords(names, "or")
+	}
+	if !c.anyMonth() {
+		names := make([]string, 0, len(c.Months))
+		for _, month := range c.Months {
+			names = append(names, enMonths[month])
+		}
+		days += " in " + joinWords(names, "and")
+	}
+
+	var clock string
+	switch {
+	case len(c.Hours)*len(c.Minutes) <= cronDescribeTimes:
+		clock = "at " + joinWords(c.times(), "and")
+	case len(c.Hours) == 24 && len(c.Minutes) == 60:
+		clock = "every minute"
+	case len(c.Hours) == 24:
+		clock = "every hour at minutes " + cronField(c.Minutes, 60)
+	case len(c.Minutes) == 60:
+		clock = "every minute during hours " + cronField(c.Hours, 24)
+	default:
+		clock = "at hours " + cronField(c.Hours, 24) + ", minutes " + cronField(c.Minutes, 60)
+	}
+	return days + " " + clock
+}
+
+// Сколько моментов в сутки перечисляем в описании cron, больше описываем полями
+const cronDescribeTimes = 4
+
+// times перечисляет моменты срабатывания за сутки: 09:00, 18:00
+func (c *Cron) times() []string {
+	times := make([]string, 0, len(c.Hours)*len(c.Minutes))
+	for _, hour := range c.Hours {
+		for _, minute := range c.Minutes {
+			times = append(times, fmt.Sprintf("%02d:%02d", hour, minute))
+		}
+	}
+	return times
+}
+
+// enWeekdayList собирает "Monday and Friday"
+func enWeekdayList(days []int) string {
+	names := make([]string, 0, len(days))
+	for _, day := range days {
+		names = append(names, enWeekdays[day])
+	}
+	return joinWords(names, "and")
+}
+
+// ruPlural выбирает форму слова для числа n: 1 день, 2 дня, 5 дней
+func ruPlural(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 19 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	}
+	return many
+}
+
+// ruEvery собирает "каждый день", "каждые 3 дня", "каждый 21 день"
+func ruEvery(n int, every, one, few, many string) string {
+	if n == 1 {
+		return every + " " + one
+	}
+	form := ruPlural(n, one, few, many)
+	if form == one {
+		return fmt.Sprintf("%s %d %s", every, n, one)
+	}
+	return fmt.Sprintf("каждые %d %s", n, form)
+}
+
+// enEvery собирает "every day", "every 3 days"
+func enEvery(n int, one, many string) string {
+	if n == 1 {
+		return "every " + one
+	}
+	return fmt.Sprintf("every %d %s", n, many)
+}
+
+// ruWeekdays собирает "по понедельникам и средам"
+func ruWeekdays(days []int) string {
+	names := make([]string, 0, len(days))
+	for _, day := range days {
+		names = append(names, ruWeekdaysDative[day])
+	}
+	return "по " + joinWords(names, "и")
+}
+
+// ruMonthDays собирает "1-го и последнего числа"
+func ruMonthDays(days []int) string {
+	names := make([]string, 0, len(days))
+	for _, day := range days {
+		switch day {
+		case -1:
+			names = append(names, "последнего")
+		case -2:
+			names = append(names, "предпоследнего")
+		default:
+			if day < 0 {
+				names = append(names, strconv.Itoa(-day)+"-го с конца")
+				continue
+			}
+			names = append(names, strconv.Itoa(day)+"-го")
+		}
+	}
+	return joinWords(names, "и") + " числа"
+}
+
+// ruMonthsOf собирает "февраля и августа" или "каждого месяца"
+func ruMonthsOf(months []int) string {
+	if len(months) == 0 {
+		return "каждого месяца"
+	}
+	names := make([]string, 0, len(months))
+	for _, month := range months {
+		names = append(names, ruMonthsGenitive[month])
+	}
+	return joinWords(names, "и")
+}
+
+// ruNthWeekdays собирает "в первый понедельник и последнюю пятницу"
+func ruNthWeekdays(days []WeekdayNum) string {
+	names := make([]string, 0, len(days))
+	for _, wn := range days {
+		gender := ruWeekdayGender[wn.Weekday]
+		var ordinal string
+		switch {
+		case wn.N == -1:
+			ordinal = []string{"последний", "последнюю", "последнее"}[gender]
+		case wn.N == -2:
+			ordinal = []string{"предпоследний", "предпоследнюю", "предпоследнее"}[gender]
+		case wn.N < 0:
+			ordinal = ruOrdinals[-wn.N-1][gender] + " с конца"
+		default:
+			ordinal = ruOrdinals[wn.N-1][gender]
+		}
+		names = append(names, ordinal+" "+ruWeekdaysAccusative[wn.Weekday])
+	}
+
+	text := joinWords(names, "и")
+	// Перед "вт" по-русски говорят "во": во вторник, во второй
+	if strings.HasPrefix(text, "вт") {
+		return "во " + text
+	}
+	return "в " + text
+}
+
+// ruPosition порядковый номер даты в BYSETPOS: 1-я, последняя, 2-я с конца
+func ruPosition(pos int) string {
+	switch {
+	case pos == -1:
+		return "последняя"
+	case pos == -2:
+		return "предпоследняя"
+	case pos < 0:
+		return fmt.Sprintf("%d-я с конца", -pos)
+	}
+	return fmt.Sprintf("%d-я", pos)
+}
+
+// enMonthDays собирает "1st and last day"
+func enMonthDays(days []int) string {
+	names := make([]string, 0, len(days))
+	for _, day := range days {
+		switch day {
+		case -1:
+			names = append(names, "last")
+		case -2:
+			names = append(names, "second to last")
+		default:
+			if day < 0 {
+				names = append(names, enNumber(-day)+" to last")
+				continue
+			}
+			names = append(names, enNumber(day))
+		}
+	}
+	return joinWords(names, "and") + " day"
+}
+
+// enMonthsOf собирает "of February and August" или "of every month"
+func enMonthsOf(months []int) string {
+	if len(months) == 0 {
+		return "of every month"
+	}
+	names := make([]string, 0, len(months))
+	for _, month := range months {
+		names = append(names, enMonths[month])
+	}
+	return "of " + joinWords(names, "and")
+}
+
+// enNthWeekdays собирает "first Monday and last Friday"
+func enNthWeekdays(days []WeekdayNum) string {
+	names := make([]string, 0, len(days))
+	for _, wn := range days {
+		names = append(names, enOrdinal(wn.N)+" "+enWeekdays[wn.Weekday])
+	}
+	return joinWords(names, "and")
+}
+
+// enOrdinal порядковое словом: first, last, third to last
+func enOrdinal(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < -5:
+		return enNumber(-n) + " to last"
+	case n > 5:
+		return enNumber(n)
+	case n < 0:
+		return enOrdinals[-n-1] + " to last"
+	}
+	return enOrdinals[n-1]
+}
+
+// enNumber порядковое цифрами: 1st, 2nd, 11th, 23rd
+func enNumber(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// joinWords перечисляет слова через запятую, последнее через союз: "a, b и c"
+func joinWords(words []string, and string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " " + and + " " + words[len(words)-1]
+}
@@ -0,0 +1,212 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Сколько промежуточных дат показываем в объяснении, остальные сворачиваем в многоточие
+const explainChainLimit = 5
+
+// Шаблоны шагов объяснения по языкам
+var explainMessages = map[string]map[string]string{
+	LangRu: {
+		"rule":      "Правило: %s.",
+		"fromDate":  "Дата задачи %s позже сегодняшней %s, отсчёт идёт от неё.",
+		"fromNow":   "Дата задачи %s не позже сегодняшней %s, ищем первую дату после сегодня.",
+		"afterDone": "Повторение считается от момента выполнения %s, а не от даты задачи.",
+		"daily":     "Ежедневная задача из прошлого переносится на сегодня: %s.",
+		"chain":     "Прибавляем интервал к дате задачи, пока не окажемся позже %s: %s.",
+		"candidate": "Первая дата по правилу после %s: %s.",
+		"rolled":    "%s - нерабочий день, переносим на %s.",
+		"skipped":   "%s пропущена, берём следующую: %s.",
+		"at":        "Время повторения: %s.",
+		"until":     "Дата %s позже until %s, серия завершена.",
+		"count":     "Это было бы повторение №%d из %d, серия завершена.",
+		"counted":   "Это повторение №%d из %d.",
+		"finished":  "Серия завершена.",
+		"none":      "Подходящей даты не нашлось.",
+		"result":    "Следующая дата: %s.",
+		"part":      "По правилу %s ближайшая дата %s.",
+		"partNone":  "По правилу %s дат больше нет.",
+		"earliest":  "Берём самую раннюю из них.",
+	},
+	LangEn: {
+		"rule":      "Rule: %s.",
+		"fromDate":  "The task date %s is after today %s, counting starts from it.",
+		"fromNow":   "The task date %s is not after today %s, looking for the first date after today.",
+		"afterDone": "The rule counts from the completion moment %s, not from the task date.",
+		"daily":     "An overdue daily task moves to today: %s.",
+		"chain":     "Adding the interval to the task date until it is after %s: %s.",
+		"candidate": "The first date matching the rule after %s: %s.",
+		"rolled":    "%s is a day off, moving to %s.",
+		"skipped":   "%s is skipped, taking the next one: %s.",
+		"at":        "Occurrence time: %s.",
+		"until":     "%s is after until %s, the series is finished.",
+		"count":     "This would be occurrence %d of %d, the series is finished.",
+		"counted":   "This is occurrence %d of %d.",
+		"finished":  "The series is finished.",
+		"none":      "No matching date was found.",
+		"result":    "Next date: %s.",
+		"part":      "The rule %s gives %s.",
+		"partNone":  "The rule %s has no more dates.",
+		"earliest":  "Taking the earliest of them.",
+	},
+}
+
+// Explain пошагово объясняет, как для задачи с датой date получилась следующая дата.
+// Возвращает шаги и саму дату в формате 20060102, пустую, если повторений больше не будет.
+func Explain(now time.Time, date, repeat, lang string) ([]string, string, error) {
+	rule, err := ParseCached(repeat)
+	if err != nil {
+		return nil, "", err
+	}
+	_, anchor, err := rule.Anchor(date, "", now.Location())
+	if err != nil {
+		return nil, "", err
+	}
+	return rule.Explain(now, anchor, lang)
+}
+
+// Explain то же, что и одноимённая функция, но для уже разобранного правила.
+// Шаги повторяют то, что делает Next, итоговая дата с ним совпадает.
+func (r Rule) Explain(now, date time.Time, lang string) ([]string, string, error) {
+	text, err := r.Describe(lang)
+	if err != nil {
+		return nil, "", err
+	}
+	if lang == "" {
+		lang = LangRu
+	}
+	msg := explainMessages[lang]
+
+	steps := []string{fmt.Sprintf(msg["rule"], text)}
+	add := func(key string, args ...any) {
+		steps = append(steps, fmt.Sprintf(msg[key], args...))
+	}
+
+	// У объединения каждое правило считается само по себе, показываем их итоги
+	if r.Kind == KindUnion {
+		for _, sub := range r.Rules {
+			sub.Except = r.Except
+			sub.Pauses = r.Pauses
+			if next, err := sub.Next(now, date); err == nil {
+				add("part", sub.String(), sub.formatMoment(next))
+			} else {
+				add("partNone", sub.String())
+			}
+		}
+		next, err := r.Next(now, date)
+		switch {
+		case errors.Is(err, ErrSeriesFinished):
+			add("finished")
+			return steps, "", nil
+		case err != nil:
+			add("none")
+			return steps, "", nil
+		}
+		add("earliest")
+		add("result", next.Format("20060102"))
+		return steps, next.Format("20060102"), nil
+	}
+
+	if r.AfterDone || r.Kind == KindSpaced {
+		add("afterDone", r.formatMoment(now))
+		now, date = r.start(now, date)
+	} else if date.After(now) {
+		add("fromDate", r.formatMoment(date), r.formatMoment(now))
+	} else {
+		add("fromNow", r.formatMoment(date), r.formatMoment(now))
+	}
+
+	// Сначала дата строго по правилу, без переносов и пропусков
+	after := now
+	if date.After(now) {
+		after = date
+	}
+	// Для правил со временем, как и в next, считаем по дням со сдвигом now на время повторения
+	rawNow, rawDate := now, date
+	if r.At != "" && !r.clocked() {
+		clock, _ := time.Parse("15:04", r.At)
+		rawNow = now.Add(-time.Duration(clock.Hour())*time.Hour - time.Duration(clock.Minute())*time.Minute)
+		rawDate = truncateDay(date)
+	}
+	raw := r.occurrence(rawNow, rawDate)
+	if r.At != "" && !r.clocked() && !raw.IsZero() {
+		raw = truncateDay(raw)
+	}
+	switch {
+	case raw.IsZero():
+	case r.Kind == KindDay && !r.Workdays && r.Interval == 1 && rawDate.Before(rawNow):
+		add("daily", r.formatMoment(raw))
+	case r.Kind == KindDay && !r.Workdays && !rawDate.After(rawNow):
+		add("chain", r.formatMoment(now), r.dayChain(rawDate, raw))
+	default:
+		add("candidate", r.formatMoment(after), r.formatMoment(raw))
+	}
+
+	// Перенос с выходных показываем, только если он что-то поменял
+	if r.Roll != "" && !raw.IsZero() {
+		if rolled := currentCalendar().Roll(raw, r.Roll); !rolled.Equal(raw) && !rolled.IsZero() {
+			add("rolled", r.formatMoment(raw), r.formatMoment(rolled))
+		}
+	}
+
+	next := r.next(now, date)
+	for !next.IsZero() && r.skipped(next) {
+		skipped := next
+		next = r.next(next, next)
+		add("skipped", r.formatMoment(skipped), r.formatMoment(next))
+	}
+	if next.IsZero() {
+		if r.Kind == KindRRule && (r.Count > 0 || !r.Until.IsZero()) {
+			add("finished")
+		} else {
+			add("none")
+		}
+		return steps, "", nil
+	}
+	if r.At != "" {
+		add("at", r.At)
+	}
+
+	if !r.Until.IsZero() && afterDay(next, r.Until) {
+		add("until", next.Format("20060102"), r.Until.Format("20060102"))
+		return steps, "", nil
+	}
+	if r.Count > 0 {
+		index := r.index(date, next)
+		if index > r.Count {
+			add("count", index, r.Count)
+			return steps, "", nil
+		}
+		add("counted", index, r.Count)
+	}
+
+	add("result", next.Format("20060102"))
+	return steps, next.Format("20060102"), nil
+}
+
+// formatMoment форматирует дату, а для правил со временем ещё и время
+func (r Rule) formatMoment(t time.Time) string {
+	if r.clocked() || (r.At != "" && (t.Hour() != 0 || t.Minute() != 0)) {
+		return t.Format("20060102 15:04")
+	}
+	return t.Format("20060102")
+}
+
+// dayChain собирает цепочку дат правила d от date до to: 20240113 → 20240120 → 20240127.
+// Длинные цепочки сворачиваются, чтобы объяснение оставалось читаемым.
+func (r Rule) dayChain(date, to time.Time) string {
+	chain := []string{r.formatMoment(date)}
+	for cur := date; cur.Before(to); {
+		cur = cur.AddDate(0, 0, r.Interval)
+		chain = append(chain, r.formatMoment(cur))
+	}
+	if len(chain) > explainChainLimit {
+		chain = append(chain[:2:2], "…", chain[len(chain)-2], chain[len(chain)-1])
+	}
+	return strings.Join(chain, " → ")
+}
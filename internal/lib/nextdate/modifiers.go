@@ -0,0 +1,183 @@
+package nextdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ключевые слова модификаторов, каждое принимает одно значение, кроме флагов
+const (
+	modUntil    = "until"    // until 20251231 - последняя допустимая дата
+	modCount    = "count"    // count 10 - всего повторений, считая дату задачи
+	modRoll     = "roll"     // roll next - перенос с выходных и праздников
+	modWorkdays = "workdays" // workdays - флаг для d, считать только рабочие дни
+	modAt       = "at"       // at 09:30 - время повторения
+	modAfter    = "after"    // after - флаг, считать следующую дату от момента выполнения
+	modMonthEnd = "monthend" // monthend clamp - что делать с 31 апреля и 29 февраля
+)
+
+var modifierKeywords = []string{modUntil, modCount, modRoll, modWorkdays, modAt, modAfter, modMonthEnd}
+
+// Модификаторы-флаги, значения у них нет
+var modifierFlags = []string{modWorkdays, modAfter}
+
+// modifier пара ключ-значение после основного правила
+type modifier struct {
+	key   string
+	value string
+}
+
+// splitModifiers делит аргументы на позиционные значения правила и модификаторы.
+// Всё, что начинается с первого ключевого слова, считается модификаторами.
+func splitModifiers(fields []string) ([]string, []modifier) {
+	for i, field := range fields {
+		if !isModifier(field) {
+			continue
+		}
+
+		mods := []modifier{}
+		rest := fields[i:]
+		for j := 0; j < len(rest); j++ {
+			mod := modifier{key: rest[j]}
+			if j+1 < len(rest) && !isModifier(rest[j+1]) {
+				mod.value = rest[j+1]
+				j++
+			}
+			mods = append(mods, mod)
+		}
+		return fields[:i], mods
+	}
+	return fields, nil
+}
+
+func isModifier(field string) bool {
+	for _, keyword := range modifierKeywords {
+		if field == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// applyModifiers заполняет правило значениями модификаторов
+func (r *Rule) applyModifiers(mods []modifier) error {
+	seen := map[string]bool{}
+	for _, mod := range mods {
+		if !isModifier(mod.key) {
+			return fmt.Errorf("лишнее значение после модификаторов: %s", mod.key)
+		}
+		if seen[mod.key] {
+			return fmt.Errorf("модификатор %s указан дважды", mod.key)
+		}
+		seen[mod.key] = true
+		flag := isFlag(mod.key)
+		if mod.value == "" && !flag {
+			return fmt.Errorf("у модификатора %s нет значения", mod.key)
+		}
+		if mod.value != "" && flag {
+			return fmt.Errorf("у модификатора %s не бывает значения: %s", mod.key, mod.value)
+		}
+
+		switch mod.key {
+		case modUntil:
+			if !r.Until.IsZero() {
+				return fmt.Errorf("условие until указано дважды")
+			}
+			until, err := time.Parse("20060102", mod.value)
+			if err != nil {
+				return fmt.Errorf("дата until должна быть в формате ГГГГММДД: %s", mod.value)
+			}
+			r.Until = until
+		case modCount:
+			if r.Count > 0 {
+				return fmt.Errorf("условие count указано дважды")
+			}
+			count, err := strconv.Atoi(mod.value)
+			if err != nil || count < 1 {
+				return fmt.Errorf("количество повторений должно быть положительным числом: %s", mod.value)
+			}
+			r.Count = count
+		case modRoll:
+			switch mod.value {
+			case RollNext, RollPrev, RollNearest:
+				r.Roll = mod.value
+			default:
+				return fmt.Errorf("политика переноса должна быть next, prev или nearest: %s", mod.value)
+			}
+		case modWorkdays:
+			if r.Kind != KindDay {
+				return fmt.Errorf("рабочие дни можно считать только в правиле d")
+			}
+			r.Workdays = true
+		case modAt:
+			if r.Kind == KindHour {
+				return fmt.Errorf("у правила h время задаётся самой задачей")
+			}
+			if r.Kind == KindCron {
+				return fmt.Errorf("у правила cron время задаётся самим выражением")
+			}
+			clock, err := time.Parse("15:04", mod.value)
+			if err != nil {
+				return fmt.Errorf("время должно быть в формате ЧЧ:ММ: %s", mod.value)
+			}
+			r.At = clock.Format("15:04")
+		case modAfter:
+			r.AfterDone = true
+		case modMonthEnd:
+			if r.Kind != KindYear && r.Kind != KindMonth && r.Kind != KindDay && altCalendars[r.Kind] == nil {
+				return fmt.Errorf("политику конца месяца понимают только правила y, m, d и правила других календарей")
+			}
+			if !isMonthEnd(mod.value) {
+				return fmt.Errorf("политика конца месяца должна быть overflow, clamp или skip: %s", mod.value)
+			}
+			r.MonthEnd = mod.value
+		}
+	}
+
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("until и count нельзя указывать вместе")
+	}
+	return nil
+}
+
+// modifiers собирает модификаторы обратно в строку, с ведущим пробелом.
+// Для RRULE условия окончания уже записаны внутри самого правила.
+func (r Rule) modifiers() string {
+	var b strings.Builder
+	if r.Workdays {
+		fmt.Fprintf(&b, " %s", modWorkdays)
+	}
+	if r.AfterDone {
+		fmt.Fprintf(&b, " %s", modAfter)
+	}
+	if r.At != "" {
+		fmt.Fprintf(&b, " %s %s", modAt, r.At)
+	}
+	if r.Roll != "" {
+		fmt.Fprintf(&b, " %s %s", modRoll, r.Roll)
+	}
+	if r.MonthEnd != "" {
+		fmt.Fprintf(&b, " %s %s", modMonthEnd, r.MonthEnd)
+	}
+	if r.Kind == KindRRule {
+		return b.String()
+	}
+	if !r.Until.IsZero() {
+		fmt.Fprintf(&b, " %s %s", modUntil, r.Until.Format("20060102"))
+	}
+	if r.Count > 0 {
+		fmt.Fprintf(&b, " %s %d", modCount, r.Count)
+	}
+	return b.String()
+}
+
+func isFlag(key string) bool {
+	for _, flag := range modifierFlags {
+		if key == flag {
+			return true
+		}
+	}
+	return false
+}
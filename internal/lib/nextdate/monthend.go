@@ -0,0 +1,152 @@
+package nextdate
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Политики для дня, которого нет в нужном месяце: 31 апреля, 29 февраля невисокосного года
+const (
+	MonthEndOverflow = "overflow" // Переполняем в следующий месяц, как AddDate: 31 апреля - 1 мая
+	MonthEndClamp    = "clamp"    // Берём последний день месяца: 31 апреля - 30 апреля
+	MonthEndSkip     = "skip"     // Пропускаем такой месяц или год
+)
+
+var (
+	monthEndMu      sync.RWMutex
+	defaultMonthEnd string // Пустая - у каждого правила своё поведение по умолчанию
+)
+
+// SetMonthEnd задаёт политику по умолчанию для правил m, y и правил других календарей без модификатора monthend.
+// На d она не действует: у него свой шаг, и 29 февраля он пропускает только с явным monthend.
+// Пустая строка возвращает поведение правил как есть: y переполняет, m пропускает.
+func SetMonthEnd(policy string) error {
+	if policy != "" && !isMonthEnd(policy) {
+		return fmt.Errorf("политика конца месяца должна быть overflow, clamp или skip: %s", policy)
+	}
+	monthEndMu.Lock()
+	defaultMonthEnd = policy
+	monthEndMu.Unlock()
+	return nil
+}
+
+func currentMonthEnd() string {
+	monthEndMu.RLock()
+	defer monthEndMu.RUnlock()
+	return defaultMonthEnd
+}
+
+func isMonthEnd(policy string) bool {
+	return policy == MonthEndOverflow || policy == MonthEndClamp || policy == MonthEndSkip
+}
+
+// monthEnd возвращает действующую политику правила: своя, по умолчанию или исторически сложившаяся
+func (r Rule) monthEnd() string {
+	if r.MonthEnd != "" {
+		return r.MonthEnd
+	}
+	if policy := currentMonthEnd(); policy != "" && r.dayOfMonth() {
+		return policy
+	}
+	// Так правила работали до появления политик
+	if r.Kind == KindMonth {
+		return MonthEndSkip
+	}
+	return MonthEndOverflow
+}
+
+// dayOfMonth проверяет, что правило назначает день месяца, только к таким относится политика по умолчанию
+func (r Rule) dayOfMonth() bool {
+	return r.Kind == KindMonth || r.Kind == KindYear || altCalendars[r.Kind] != nil
+}
+
+// daysIn возвращает количество дней в месяце
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// isLeapDay проверяет, что дата это 29 февраля
+func isLeapDay(t time.Time) bool {
+	return t.Month() == time.February && t.Day() == 29
+}
+
+// nextYearDate ищет дату раз в step лет строго после max(now, date) для clamp и skip.
+// leap - серия началась 29 февраля: при clamp задача на 28-е невисокосного года
+// в високосный год возвращается на 29-е, иначе после первого же переноса осталась бы на 28-м.
+func nextYearDate(now, date time.Time, step int, policy string, leap bool) time.Time {
+	after := now
+	if date.After(now) {
+		after = date
+	}
+
+	day := date.Day()
+	if policy == MonthEndClamp && leap && date.Month() == time.February {
+		day = 29
+	}
+
+	// 400 лет - полный цикл календаря, дальше ничего нового не найдётся
+	for year := date.Year() + step; year <= after.Year()+400+step; year += step {
+		var next time.Time
+		switch last := daysIn(year, date.Month()); {
+		case day <= last:
+			next = time.Date(year, date.Month(), day, date.Hour(), date.Minute(), 0, 0, date.Location())
+		case policy == MonthEndClamp:
+			next = time.Date(year, date.Month(), last, date.Hour(), date.Minute(), 0, 0, date.Location())
+		default:
+			continue
+		}
+		if next.After(after) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// leapOrigin проверяет, что серия правила началась 29 февраля
+func (r Rule) leapOrigin() bool {
+	origin, err := time.Parse("20060102", r.Origin)
+	return err == nil && isLeapDay(origin)
+}
+
+// dayBase возвращает дату, от которой правило d считает следующий шаг.
+// При clamp повторение с 29 февраля стоит на 28-м, но шаг отсчитывается от 29-го,
+// иначе серия навсегда сдвинулась бы на день. Отличить перенесённое 28-е от настоящего
+// можно только по началу серии.
+func (r Rule) dayBase(date time.Time) time.Time {
+	if r.Workdays || r.AfterDone || r.monthEnd() != MonthEndClamp || date.Month() != time.February ||
+		date.Day() != 28 || daysIn(date.Year(), time.February) != 29 {
+		return date
+	}
+	origin, err := time.ParseInLocation("20060102", r.Origin, date.Location())
+	if err != nil {
+		return date
+	}
+	leap := date.AddDate(0, 0, 1)
+	if daysBetween(origin, date)%r.Interval != 0 && daysBetween(origin, leap)%r.Interval == 0 {
+		return leap
+	}
+	return date
+}
+
+// leapDay применяет политику к повторению правила d, выпавшему на 29 февраля.
+// clamp переносит его на 28-е, если тот ещё не прошёл, skip берёт следующее повторение.
+func (r Rule) leapDay(now, date, next time.Time) time.Time {
+	if next.IsZero() || !isLeapDay(next) {
+		return next
+	}
+
+	switch r.monthEnd() {
+	case MonthEndClamp:
+		after := now
+		if date.After(now) {
+			after = date
+		}
+		if clamped := next.AddDate(0, 0, -1); clamped.After(after) {
+			return clamped
+		}
+	case MonthEndOverflow:
+		return next
+	}
+	return r.dayOccurrence(next, next)
+}
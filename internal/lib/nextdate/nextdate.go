@@ -1,230 +1,186 @@
 package nextdate
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
 )
 
-func NextDate(now time.Time, date string, repeat string) (string, error) {
-	var nextDate string
-	var nonAlphanumericRegex = regexp.MustCompile(`[^a-zA-Z0-9 ]+`)
+// Сколько разобранных правил держим в кэше. Строки приходят от пользователей,
+// поэтому кэш ограничен: при переполнении он просто очищается.
+const rulesCacheSize = 1024
 
-	// Проверим, не передали ли нам пустую строку.
-	// Так же уберём из неё все символы не алфавитного типа.
-	if nonAlphanumericRegex.ReplaceAllString(strings.TrimSpace(repeat), "") == "" {
-		return "", fmt.Errorf("обнаружена некорректная строка в атрибуте repeat")
-	}
+// Кэш уже разобранных правил, ключ - каноничная запись правила Rule.String
+var (
+	rulesMu    sync.RWMutex
+	rulesCache = map[string]Rule{}
+)
 
-	// Если у нас пустое значение repeat, будем удалять таску
-	if repeat == "" {
-		return "mark for delete", nil
-	}
+// NextDate вычисляет следующую дату задачи в формате 20060102
+func NextDate(now time.Time, date string, repeat string) (string, error) {
+	nextDate, _, err := NextDateTime(now, date, "", repeat)
+	return nextDate, err
+}
 
-	// Проверяем, дали ли нам корректную дату
-	dateParse, err := time.Parse("20060102", date)
+// NextDateTime вычисляет следующую дату и время задачи.
+// clock - время задачи в формате 15:04, может быть пустым.
+// Если ни у задачи, ни у правила нет времени, вернётся пустое время.
+func NextDateTime(now time.Time, date, clock, repeat string) (string, string, error) {
+	rule, err := ParseCached(repeat)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	repeatSlice := strings.Split(repeat, " ")
-
-	rule := repeatSlice[0]
-	value := ""
+	return rule.NextDateTime(now, date, clock)
+}
 
-	// Проверим, что нам пришли данные о днях или месяцах
-	if len(repeatSlice) > 1 {
-		// И проверим, что они не пустые
-		if len(strings.TrimSpace(repeatSlice[1])) > 0 {
-			value = repeatSlice[1]
-		}
+// NextDateTime то же, что и одноимённая функция, но для уже разобранного правила.
+// Дата задачи понимается в часовом поясе now.
+func (r Rule) NextDateTime(now time.Time, date, clock string) (string, string, error) {
+	rule, anchor, err := r.Anchor(date, clock, now.Location())
+	if err != nil {
+		return "", "", err
 	}
 
-	switch rule {
-	case "d":
-		// Пробуем перевести число дней в int
-		valueInt, err := strconv.Atoi(value)
-		// Если нам передали непереводимое в число значение, выходим
-		if err != nil {
-			return "", fmt.Errorf("значение дня передано некорректно: %s", err)
-		}
-
-		// По ТЗ, если значение больше чем 400, выходим
-		if valueInt > 400 {
-			return "", fmt.Errorf("превышен максимально допустимый интервал количества дней")
-		}
-
-		// Проверим на каждодневность
-		// Если дата < чем сейчас, перенесём на сегодня
-		if valueInt == 1 && dateParse.Before(now) {
-			return fmt.Sprint(now.Format("20060102")), nil
-		} else if valueInt == 1 && dateParse.After(now) {
-			// Если > чем сейчас, перенос на следующий день
-			return fmt.Sprint(dateParse.AddDate(0, 0, valueInt).Format("20060102")), nil
-		}
-
-		// Необходимо отдельное условие для событий
-		// Когда date уже больше чем now
-		if dateParse.After(now) {
-			nextDate = fmt.Sprint(dateParse.AddDate(0, 0, valueInt).Format("20060102"))
-			return nextDate, nil
-		}
-
-		// Запустим цикл, который будет добавлять к дате наш интервал
-		for {
-			if dateParse.After(now) {
-				break
-			}
-			// Добавляем интервал
-			dateParse = dateParse.AddDate(0, 0, valueInt)
-		}
+	next, err := rule.Next(now, anchor)
+	if err != nil {
+		return "", "", err
+	}
 
-		nextDate = dateParse.Format("20060102")
+	if !rule.HasTime() {
+		return next.Format("20060102"), "", nil
+	}
+	return next.Format("20060102"), next.Format("15:04"), nil
+}
 
-	case "y":
-		// Необходимо отдельное условие для событий
-		// Когда date уже больше чем now
-		if dateParse.After(now) {
-			nextDate = fmt.Sprint(dateParse.AddDate(1, 0, 0).Format("20060102"))
-			return nextDate, nil
-		}
+// Anchor переводит дату и время задачи в момент, от которого считаются повторения.
+// Время задачи становится временем каждого повторения, если в правиле своего нет.
+// Дата и время считаются заданными в часовом поясе loc.
+func (r Rule) Anchor(date, clock string, loc *time.Location) (Rule, time.Time, error) {
+	// Проверяем, дали ли нам корректную дату
+	anchor, err := time.ParseInLocation("20060102", date, loc)
+	if err != nil {
+		return r, time.Time{}, err
+	}
+	if clock == "" {
+		return r, anchor, nil
+	}
 
-		// Запустим цикл, который будет добавлять к дате наш интервал,
-		// пока дата не перерастёт now
-		for {
-			// Сразу добавляем интервал
-			dateParse = dateParse.AddDate(1, 0, 0)
-			// Если старт позже чем now - выходим
-			if dateParse.After(now) {
-				break
-			}
-		}
+	clockParse, err := time.Parse("15:04", clock)
+	if err != nil {
+		return r, time.Time{}, fmt.Errorf("время должно быть в формате ЧЧ:ММ: %s", clock)
+	}
+	if r.Kind == KindUnion {
+		r = r.anchorUnion(clockParse.Format("15:04"))
+	} else if r.At == "" && !r.clocked() {
+		r.At = clockParse.Format("15:04")
+	}
 
-		nextDate = dateParse.Format("20060102")
+	return r, withClock(anchor, clockParse), nil
+}
 
-	case "w":
-		// Обработаем сразу ошибку пустого value
-		if value == "" {
-			return "", fmt.Errorf("при передаче правила %s, пришел пустой день недели", rule)
-		}
-		// Берём числовое значение текущего дня недели
-		today := int(now.Weekday())
-		// Для начала поменяем последовательность, у нас начало с понедельника
-		if today == 0 {
-			today = 7
-		}
+// NextDates возвращает n ближайших дат повторения, первая совпадает с NextDate.
+// Каждая следующая дата считается от предыдущей так же, как это делает TaskDone.
+func NextDates(now time.Time, date string, repeat string, n int) ([]string, error) {
+	dateParse, err := time.ParseInLocation("20060102", date, now.Location())
+	if err != nil {
+		return nil, err
+	}
 
-		// Отдельно сделаем проверку на завтрашний день
-		// Чтобы не упасть на today + 1 в воскресенье
-		var tomorrow int
-		if today == 7 {
-			tomorrow = 1
-		} else {
-			tomorrow = today + 1
-		}
+	rule, err := ParseCached(repeat)
+	if err != nil {
+		return nil, err
+	}
 
-		// Разобьем значение value, в случае, если там более 1 значения
-		week := strings.Split(value, ",")
+	// Без сохранённого начала серии она начинается с переданной даты
+	if rule.Origin == "" {
+		rule.Origin = date
+	}
 
-		// Создадим отдельный слайс куда перекинем все значения week в int
-		weekInts := []int{}
-		for _, day := range week {
-			dayInt, err := strconv.Atoi(day)
-			if err != nil {
-				return "", err
-			}
-			weekInts = append(weekInts, dayInt)
-		}
+	// Закончившаяся серия для предпросмотра это просто отсутствие дат
+	dates := []string{}
+	n, deadline := rule.scriptLimits(n)
+	next, err := rule.Next(now, dateParse)
+	for i := 0; i < n && err == nil && !scriptExpired(deadline); i++ {
+		dates = append(dates, next.Format("20060102"))
+		// Дальше серия считается от только что найденной даты
+		rule.Count = rule.Remaining(dateParse, next)
+		rule = rule.advance()
+		dateParse = next
+		next, err = rule.Next(next, next)
+	}
+	if err != nil && !errors.Is(err, ErrSeriesFinished) {
+		return nil, err
+	}
 
-		// Пройдём по слайсу weekInts чтобы проверить, что у нас нет числа меньше 1 и больше 7
-		for _, day := range weekInts {
-			if day < 1 || day > 7 {
-				return "", fmt.Errorf("при передаче правила %s, пришел некорректный номер дня недели: %d", rule, day)
-			}
-		}
+	return dates, nil
+}
 
-		// Узнаем откуда нам производить отсчёт
-		var dateStart time.Time
-		if dateParse.After(now) {
-			dateStart = dateParse
-		} else {
-			dateStart = now
-		}
+// Between возвращает повторения задачи с датой date, попавшие в дни с from по to включительно.
+// Сама date тоже считается повторением. Повторений отдаётся не больше limit,
+// для правил со скриптом ещё меньше и не дольше scriptBudget.
+func (r Rule) Between(date, from, to time.Time, limit int) []time.Time {
+	from, end := truncateDay(from), truncateDay(to).AddDate(0, 0, 1)
+	limit, deadline := r.scriptLimits(limit)
+	if r.Origin == "" {
+		r.Origin = date.Format("20060102")
+	}
 
-		// Пройдем по слайсу, сколько бы значений не было, начинаем с завтра
-		// Создал итератор так как к i привязываться нельзя из-за старта today + 1
-		j := 1
-		for i := tomorrow; i < 8; i++ {
-			if search(i, weekInts) {
-				nextDate = fmt.Sprint(dateStart.AddDate(0, 0, j).Format("20060102"))
-				break
-			}
-			if i == 7 {
-				i = 0
-			}
-			j++
-		}
-	case "m":
-		// Проверяем что передана обязательная последовательность
-		if value == "" {
-			return "", fmt.Errorf("при передаче правила %s, пришел пустой день месяца", rule)
+	dates := []time.Time{}
+	cur := date
+	// Задачу из далёкого прошлого не гоним по всей серии, сразу ищем первую дату окна
+	if cur.Before(from) {
+		next, err := r.Next(from.AddDate(0, 0, -1), cur)
+		if err != nil {
+			return dates
 		}
+		r.Count = r.Remaining(cur, next)
+		cur = next
+	}
 
-		// Добавим переменные для проверки, есть ли необязательная последовательность
-		month := ""
-		intMonths := []int{}
-		if len(repeatSlice) > 2 {
-			month = repeatSlice[2]
+	for len(dates) < limit && cur.Before(end) && !scriptExpired(deadline) {
+		if !cur.Before(from) {
+			dates = append(dates, cur)
 		}
-		if month != "" {
-			monthsOfYear := strings.Split(month, ",")
-
-			// Переводим месяца в int
-			for _, month := range monthsOfYear {
-				intMonth, err := strconv.Atoi(month)
-				if err != nil {
-					return "", err
-				}
-				if intMonth > 12 || intMonth < 1 {
-					return "", fmt.Errorf("числовое значение месяца некорректно: %d", intMonth)
-				}
-				intMonths = append(intMonths, intMonth)
-			}
+		// Закончившаяся серия, как и в NextDates, это просто конец дат
+		next, err := r.Next(cur, cur)
+		if err != nil {
+			break
 		}
+		r.Count = r.Remaining(cur, next)
+		r = r.advance()
+		cur = next
+	}
 
-		// Берём все дни из обязательной последовательности
-		daysOfMonth := strings.Split(value, ",")
+	return dates
+}
 
-		// Переведём строковые значения дней в числа
-		intDaysOfMonth := []int{}
-		for _, day := range daysOfMonth {
-			intDay, err := strconv.Atoi(day)
-			if err != nil || intDay > 31 || intDay < -2 {
-				return "", err
-			}
+// ParseCached работает как Parse, но запоминает успешно разобранные правила,
+// чтобы не разбирать одну и ту же строку на каждый запрос.
+// Запоминается только каноничная запись, так что варианты одного правила
+// с пробелами и повторами кэш не раздувают.
+func ParseCached(repeat string) (Rule, error) {
+	rulesMu.RLock()
+	rule, ok := rulesCache[repeat]
+	rulesMu.RUnlock()
+	if ok {
+		return rule, nil
+	}
 
-			intDaysOfMonth = append(intDaysOfMonth, intDay)
-		}
+	rule, err := Parse(repeat)
+	if err != nil {
+		return Rule{}, err
+	}
 
-		// Узнаем откуда нам производить отсчёт
-		var dateStart time.Time
-		if dateParse.After(now) {
-			dateStart = dateParse
-		} else {
-			dateStart = now
-		}
-		nextDate = findNextDate(dateStart, intDaysOfMonth, intMonths).Format("20060102")
-		// Если не нашлось кандидатов и нам вернулся дефолтный time.Time, значит ошибка
-		if nextDate == "00010101" {
-			return "", nil
-		}
-	default:
-		return "", fmt.Errorf("неверный формат repeat")
+	rulesMu.Lock()
+	if len(rulesCache) >= rulesCacheSize {
+		rulesCache = map[string]Rule{}
 	}
+	rulesCache[rule.String()] = rule
+	rulesMu.Unlock()
 
-	return nextDate, nil
+	return rule, nil
 }
 
 // Функция для сверки есть ли в слайсе искомое
@@ -238,9 +194,10 @@ func search(target int, slice []int) bool {
 }
 
 // findNextDate находит ближайшую дату на основе правил.
-func findNextDate(now time.Time, days, months []int) time.Time {
+// Месяцы перебираются по порядку, первый месяц с подходящим днём и даёт ответ.
+// Через 400 лет календарь повторяется, дальше искать бессмысленно.
+func findNextDate(now time.Time, days, months []int, policy string) time.Time {
 	currentYear, currentMonth := now.Year(), int(now.Month())
-	var candidates []time.Time
 
 	// Определяем, какие месяцы нужно учитывать
 	monthSet := map[int]bool{}
@@ -256,7 +213,7 @@ func findNextDate(now time.Time, days, months []int) time.Time {
 	}
 
 	// Генерируем даты
-	for monthOffset := 0; monthOffset < 24; monthOffset++ { // 2 года вперёд для поиска
+	for monthOffset := 0; monthOffset < monthSearchMonths; monthOffset++ {
 		month := (currentMonth+monthOffset-1)%12 + 1
 		year := currentYear + (currentMonth+monthOffset-1)/12
 
@@ -265,32 +222,84 @@ func findNextDate(now time.Time, days, months []int) time.Time {
 			continue
 		}
 
-		// Для каждого указанного дня месяца добавляем кандидатов
+		// Среди дней месяца берём самый ранний после now
+		var minDate time.Time
 		for _, day := range days {
-			date := calculateDate(year, month, day)
-			if date.After(now) {
-				candidates = append(candidates, date)
+			date := calculateDate(year, month, day, now.Location(), policy)
+			if !date.IsZero() && date.After(now) && (minDate.IsZero() || date.Before(minDate)) {
+				minDate = date
 			}
 		}
+		if !minDate.IsZero() {
+			return minDate
+		}
 	}
 
-	// Найти минимальную дату
-	if len(candidates) == 0 {
-		return time.Time{}
+	return time.Time{}
+}
+
+// findNextDateEvery как findNextDate, но берёт только каждый interval-й месяц,
+// считая от месяца anchor. Сам месяц anchor тоже подходит.
+func findNextDateEvery(now, anchor time.Time, days []int, interval int, policy string) time.Time {
+	// Сколько месяцев от anchor до now, округляем вниз до кратного шагу
+	offset := (now.Year()-anchor.Year())*12 + int(now.Month()) - int(anchor.Month())
+	offset -= (offset%interval + interval) % interval
+
+	for i := 0; i < monthSearchMonths; i, offset = i+1, offset+interval {
+		month := time.Date(anchor.Year(), anchor.Month()+time.Month(offset), 1, 0, 0, 0, 0, now.Location())
+
+		var minDate time.Time
+		for _, day := range days {
+			date := calculateDate(month.Year(), int(month.Month()), day, now.Location(), policy)
+			if !date.IsZero() && date.After(now) && (minDate.IsZero() || date.Before(minDate)) {
+				minDate = date
+			}
+		}
+		if !minDate.IsZero() {
+			return minDate
+		}
 	}
-	minDate := candidates[0]
-	for _, d := range candidates {
-		if d.Before(minDate) {
-			minDate = d
+
+	return time.Time{}
+}
+
+// Сколько месяцев вперёд просматриваем для m и mw.
+// 400 лет - полный цикл григорианского календаря, дальше всё повторяется.
+const (
+	monthSearchMonths   = 400 * 12
+	weekdaySearchMonths = monthSearchMonths
+)
+
+// findNextWeekday находит ближайший N-ный день недели месяца строго позже now
+func findNextWeekday(now time.Time, days []WeekdayNum, months []int) time.Time {
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	for monthOffset := 0; monthOffset < weekdaySearchMonths; monthOffset++ {
+		monthStart := first.AddDate(0, monthOffset, 0)
+		if len(months) > 0 && !search(int(monthStart.Month()), months) {
+			continue
+		}
+
+		// Кандидаты в пределах месяца, берём самый ранний после now
+		var next time.Time
+		for _, date := range expandWeekdays(days, monthStart, monthStart.AddDate(0, 1, -1)) {
+			if date.After(now) && (next.IsZero() || date.Before(next)) {
+				next = date
+			}
+		}
+		if !next.IsZero() {
+			return next
 		}
 	}
-	return minDate
+
+	return time.Time{}
 }
 
 // calculateDate вычисляет дату на основе года, месяца и дня (включая -1 и -2).
-func calculateDate(year, month, day int) time.Time {
+// Если такого дня в месяце нет, поступает по политике policy.
+func calculateDate(year, month, day int, loc *time.Location, policy string) time.Time {
 	// Определяем количество дней в месяце
-	lastDay := time.Date(year, time.Month(month+1), 0, 0, 0, 0, 0, time.Local).Day()
+	lastDay := time.Date(year, time.Month(month+1), 0, 0, 0, 0, 0, loc).Day()
 	var targetDay int
 	if day > 0 {
 		targetDay = day
@@ -301,10 +310,20 @@ func calculateDate(year, month, day int) time.Time {
 	}
 
 	// Проверяем, что день в допустимых пределах
-	if targetDay < 1 || targetDay > lastDay {
+	if targetDay < 1 {
 		return time.Time{} // Возвращаем "нулевую" дату, если недопустимо
 	}
+	if targetDay > lastDay {
+		switch policy {
+		case MonthEndClamp:
+			targetDay = lastDay
+		case MonthEndOverflow:
+			// time.Date сам перенесёт лишние дни в следующий месяц
+		default:
+			return time.Time{}
+		}
+	}
 
 	// Возвращаем рассчитанную дату
-	return time.Date(year, time.Month(month), targetDay, 0, 0, 0, 0, time.Local)
+	return time.Date(year, time.Month(month), targetDay, 0, 0, 0, 0, loc)
 }
@@ -0,0 +1,689 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Насколько далеко вперёд оракул перебирает дни.
+// Если за это время ничего не нашлось, случай считается непроверяемым.
+const oracleYears = 30
+
+// Начало диапазона случайных дат
+var oracleEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// oracleNext наивно ищет следующую дату: перебирает дни по одному после max(now, date).
+// Написан без общих с пакетом функций, чтобы не повторять его ошибки.
+func oracleNext(now, date time.Time, r Rule) (time.Time, bool) {
+	// Историческая поблажка d 1: просроченная задача переносится на сегодня
+	if r.Kind == KindDay && r.Interval == 1 && date.Before(now) {
+		return now, true
+	}
+
+	start := date
+	if now.After(date) {
+		start = now
+	}
+	end := start.AddDate(oracleYears, 0, 0)
+	for day := start.AddDate(0, 0, 1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if oracleMatch(r, date, day) {
+			return day, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// oracleMatch проверяет, подходит ли день day правилу r для задачи с датой date
+func oracleMatch(r Rule, date, day time.Time) bool {
+	weekday := (int(day.Weekday())+6)%7 + 1
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	switch r.Kind {
+	case KindDay:
+		diff := dayNumber(day) - dayNumber(date)
+		return diff > 0 && diff%r.Interval == 0
+
+	case KindYear:
+		if len(r.YearDates) > 0 {
+			for _, d := range r.YearDates {
+				if d.Month == int(day.Month()) && (d.Day == day.Day() || (d.Day == -1 && day.Day() == last) || (d.Day == -2 && day.Day() == last-1)) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(r.Weeks) > 0 {
+			_, week := day.ISOWeek()
+			return oracleContains(r.Weeks, week) && oracleContains(r.Weekdays, weekday)
+		}
+		step := 1
+		if r.Interval > 1 {
+			step = r.Interval
+		}
+		years := day.Year() - date.Year()
+		return years > 0 && years%step == 0 && day.Month() == date.Month() && day.Day() == date.Day()
+
+	case KindWeek:
+		if !oracleContains(r.Weekdays, weekday) {
+			return false
+		}
+		if r.Interval > 1 {
+			monday := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+			return (dayNumber(day)-dayNumber(monday))/7%r.Interval == 0
+		}
+		return true
+
+	case KindMonth:
+		if len(r.Months) > 0 && !oracleContains(r.Months, int(day.Month())) {
+			return false
+		}
+		if r.Interval > 1 {
+			months := (day.Year()-date.Year())*12 + int(day.Month()) - int(date.Month())
+			if months%r.Interval != 0 {
+				return false
+			}
+		}
+		for _, d := range r.MonthDays {
+			if d == day.Day() || (d == -1 && day.Day() == last) || (d == -2 && day.Day() == last-1) {
+				return true
+			}
+		}
+		return false
+
+	case KindMonthWeekday:
+		if len(r.Months) > 0 && !oracleContains(r.Months, int(day.Month())) {
+			return false
+		}
+		for _, wn := range r.NthWeekdays {
+			if wn.Weekday != weekday {
+				continue
+			}
+			if (wn.N > 0 && (day.Day()-1)/7+1 == wn.N) || (wn.N < 0 && (last-day.Day())/7+1 == -wn.N) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// dayNumber номер дня от начала эпохи Unix
+func dayNumber(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func oracleContains(list []int, target int) bool {
+	for _, v := range list {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// genRepeat собирает правило из случайных чисел, так фаззер перебирает и виды правил, и их значения
+func genRepeat(kind uint8, a, b uint32) string {
+	switch kind % 8 {
+	case 0:
+		return fmt.Sprintf("d %d", a%maxDayInterval+1)
+	case 1:
+		repeat := "w " + genList(a, 1, 7)
+		if b%3 == 0 {
+			repeat += fmt.Sprintf(" /%d", b%4+2)
+		}
+		return repeat
+	case 2:
+		repeat := "m " + genMonthDays(a)
+		if b%2 == 0 {
+			repeat += " " + genList(b>>1, 1, 12)
+		}
+		return repeat
+	case 3:
+		return fmt.Sprintf("m %s /%d", genMonthDays(a), b%12+2)
+	case 4:
+		n := int(a%5) + 1
+		if a&0x100 != 0 {
+			n = -n
+		}
+		repeat := fmt.Sprintf("mw %d:%d", n, (a>>9)%7+1)
+		if b%2 == 0 {
+			repeat += " " + genList(b>>1, 1, 12)
+		}
+		return repeat
+	case 6:
+		// Только дни, которые бывают в любом месяце: переполнение оракул не умеет
+		dates := make([]string, 0, 3)
+		for i := uint32(0); i <= a%3; i++ {
+			day := int((a>>(2+6*i))%31) - 2
+			if day == 0 {
+				day = 1
+			}
+			dates = append(dates, fmt.Sprintf("%d.%d", day, (b>>(4*i))%12+1))
+		}
+		return "y " + strings.Join(dates, ",")
+	case 7:
+		return fmt.Sprintf("y w%s %s", genList(a, 1, 53), genList(b, 1, 7))
+	default:
+		if a%3 == 0 {
+			return "y"
+		}
+		return fmt.Sprintf("y %d", a%5+1)
+	}
+}
+
+// genList выбирает непустое подмножество чисел min..max по битам mask
+func genList(mask uint32, min, max int) string {
+	var items []string
+	for v := min; v <= max; v++ {
+		if mask&(1<<(v-min)) != 0 {
+			items = append(items, fmt.Sprint(v))
+		}
+	}
+	if len(items) == 0 {
+		items = append(items, fmt.Sprint(min+int(mask)%(max-min+1)))
+	}
+	return strings.Join(items, ",")
+}
+
+// genMonthDays выбирает от одного до трёх дней месяца, включая -1 и -2
+func genMonthDays(a uint32) string {
+	days := make([]string, 0, 3)
+	for i := uint32(0); i <= a%3; i++ {
+		day := int((a>>(2+6*i))%33) - 2
+		if day == 0 {
+			day = 1
+		}
+		days = append(days, fmt.Sprint(day))
+	}
+	return strings.Join(days, ",")
+}
+
+// checkNextDate сверяет NextDate с оракулом и проверяет инварианты
+func checkNextDate(t *testing.T, now, date time.Time, repeat string) {
+	t.Helper()
+
+	rule, err := Parse(repeat)
+	if err != nil {
+		return
+	}
+	// 29 февраля правило y переполняет в 1 марта, оракул такого не умеет
+	if rule.Kind == KindYear && date.Month() == time.February && date.Day() == 29 {
+		return
+	}
+
+	got, err := NextDate(now, date.Format("20060102"), repeat)
+	want, ok := oracleNext(now, date, rule)
+	if !ok {
+		return
+	}
+	if err != nil {
+		t.Fatalf("NextDate(%s, %s, %q): ошибка %v, оракул ждёт %s", now.Format("20060102"), date.Format("20060102"), repeat, err, want.Format("20060102"))
+	}
+
+	// Результат всегда позже now, кроме поблажки для d 1
+	quirk := rule.Kind == KindDay && rule.Interval == 1 && date.Before(now)
+	if !quirk && got <= now.Format("20060102") {
+		t.Fatalf("NextDate(%s, %s, %q) = %s, а должна быть позже now", now.Format("20060102"), date.Format("20060102"), repeat, got)
+	}
+	if got != want.Format("20060102") {
+		t.Fatalf("NextDate(%s, %s, %q) = %s, оракул: %s", now.Format("20060102"), date.Format("20060102"), repeat, got, want.Format("20060102"))
+	}
+
+	// Каноничная запись разбирается в саму себя и даёт ту же дату
+	canon := rule.String()
+	again, err := Parse(canon)
+	if err != nil || again.String() != canon {
+		t.Fatalf("каноничная запись %q правила %q не идемпотентна: %q, %v", canon, repeat, again.String(), err)
+	}
+	if next, err := NextDate(now, date.Format("20060102"), canon); err != nil || next != got {
+		t.Fatalf("NextDate по каноничной записи %q = %s, %v, а по %q = %s", canon, next, err, repeat, got)
+	}
+}
+
+func FuzzNextDate(f *testing.F) {
+	f.Add(uint16(8791), int16(-13), uint8(0), uint32(6), uint32(0))      // d 7
+	f.Add(uint16(8791), int16(6), uint8(1), uint32(5), uint32(3))        // w 1,3 /2 в будущем
+	f.Add(uint16(8791), int16(-1), uint8(2), uint32(0x3ffff), uint32(0)) // m с месяцами
+	f.Add(uint16(8791), int16(35), uint8(3), uint32(29<<2), uint32(1))   // m 27 /3
+	f.Add(uint16(8791), int16(0), uint8(4), uint32(0x104), uint32(1))    // mw -5:1
+	f.Add(uint16(8791), int16(-400), uint8(5), uint32(1), uint32(0))     // y 2
+
+	f.Fuzz(func(t *testing.T, nowOffset uint16, dateOffset int16, kind uint8, a, b uint32) {
+		now := oracleEpoch.AddDate(0, 0, int(nowOffset)%36500)
+		date := now.AddDate(0, 0, int(dateOffset)%1500)
+		checkNextDate(t, now, date, genRepeat(kind, a, b))
+	})
+}
+
+// Тот же фаззинг, но на фиксированных случайных данных, чтобы он шёл в обычном go test
+func TestNextDateOracle(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 3000; i++ {
+		now := oracleEpoch.AddDate(0, 0, rnd.Intn(36500))
+		date := now.AddDate(0, 0, rnd.Intn(3000)-1500)
+		checkNextDate(t, now, date, genRepeat(uint8(rnd.Intn(256)), rnd.Uint32(), rnd.Uint32()))
+	}
+}
+
+// Правила w и m по дням: из будущей даты, из прошлой и ровно на now
+func TestNextDateOracleWeekMonth(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	repeats := []string{"w 1", "w 7", "w 1,3,5", "w 2,4 /2", "w 6 /3", "m 1", "m 31", "m -1", "m -2,15", "m 29 2", "m 1,-1 2,8", "m 15 /3"}
+	for _, repeat := range repeats {
+		for offset := -40; offset <= 40; offset++ {
+			checkNextDate(t, now, now.AddDate(0, 0, offset), repeat)
+		}
+	}
+}
+
+func TestCronConversion(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		native string // Пусто, если эквивалента нет
+		cron   string // Пусто, если в cron не перевести
+	}{
+		{"0 9 * * 1-5", "w 1,2,3,4,5 at 09:00", "0 9 * * 1-5"},
+		{"30 7 * * *", "d 1 at 07:30", "30 7 * * *"},
+		{"0 0 1,15 * *", "m 1,15 at 00:00", "0 0 1,15 * *"},
+		{"0 0 31 JAN,MAR *", "m 31 1,3 at 00:00", "0 0 31 1,3 *"},
+		{"*/30 9-17 * * *", "", "0,30 9-17 * * *"},
+		{"0 0 13 * 5", "", "0 0 13 * 5"},
+		{"0 0 */2 * 1", "", "0 0 */2 * 1"},
+		{"0 0 */2,4 * 5", "", "0 0 */2,4 * 5"},
+		{"0 0 1 * */7", "", "0 0 1 * */7"},
+		{"0 0 1-31 * 1", "d 1 at 00:00", "0 0 * * *"},
+		{"w 6,7", "w 6,7", "0 0 * * 6,7"},
+		{"m -1", "m -1", ""},
+		{"d 2", "d 2", ""},
+		{"w 1 count 3", "w 1 count 3", ""},
+	}
+	for _, v := range tbl {
+		rule, err := Parse(v.repeat)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", v.repeat, err)
+		}
+		got := ""
+		if native, ok := rule.Native(); ok {
+			got = native.String()
+		}
+		if got != v.native {
+			t.Errorf("Native(%q) = %q, ожидалось %q", v.repeat, got, v.native)
+		}
+
+		cron, err := rule.ToCron()
+		if (err != nil) != (v.cron == "") || cron != v.cron {
+			t.Errorf("ToCron(%q) = %q, %v, ожидалось %q", v.repeat, cron, err, v.cron)
+		}
+	}
+}
+
+func TestRRuleNeverFires(t *testing.T) {
+	for _, repeat := range []string{
+		"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=6",
+		"FREQ=MONTHLY;BYDAY=6MO,-6FR",
+		"FREQ=YEARLY;BYMONTH=3;BYDAY=6TU",
+		"FREQ=YEARLY;BYDAY=MO;BYSETPOS=54",
+		"FREQ=WEEKLY;BYDAY=MO,TU;BYSETPOS=3",
+		"FREQ=DAILY;BYSETPOS=2",
+		"FREQ=MONTHLY;BYMONTHDAY=1,15;BYSETPOS=-3",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+	} {
+		if _, err := Parse(repeat); !errors.Is(err, ErrNeverFires) {
+			t.Errorf("Parse(%q): ошибка %v, ожидалась ErrNeverFires", repeat, err)
+		}
+	}
+
+	// Редкие, но возможные даты
+	for _, repeat := range []string{
+		"FREQ=MONTHLY;BYDAY=MO;BYSETPOS=5",
+		"FREQ=YEARLY;BYDAY=53MO",
+		"FREQ=YEARLY;BYDAY=MO;BYSETPOS=-53",
+		"FREQ=MONTHLY;BYDAY=6MO,1FR",
+		"FREQ=MONTHLY;BYMONTHDAY=1,15;BYSETPOS=-2",
+	} {
+		if _, err := Parse(repeat); err != nil {
+			t.Errorf("Parse(%q): %v", repeat, err)
+		}
+	}
+}
+
+func TestRRuleInterval(t *testing.T) {
+	for _, repeat := range []string{
+		"FREQ=DAILY;INTERVAL=9223372036854775807",
+		"FREQ=MONTHLY;INTERVAL=9223372036854775807",
+		"FREQ=YEARLY;INTERVAL=4611686018427387904",
+		"FREQ=DAILY;INTERVAL=100000",
+		"FREQ=WEEKLY;INTERVAL=53",
+	} {
+		if _, err := Parse(repeat); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Parse(%q): ошибка %v, ожидалась ErrInvalidValue", repeat, err)
+		}
+	}
+
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	for repeat, want := range map[string]string{
+		"FREQ=DAILY;INTERVAL=400":        "20250204",
+		"FREQ=WEEKLY;INTERVAL=52":        "20241230",
+		"FREQ=MONTHLY;INTERVAL=120":      "20340101",
+		"FREQ=YEARLY;INTERVAL=100":       "21240101",
+		"FREQ=YEARLY;INTERVAL=3;COUNT=2": "20270101",
+	} {
+		got, err := NextDate(now, "20240101", repeat)
+		if err != nil || got != want {
+			t.Errorf("NextDate(%q) = %q, %v, ожидалось %s", repeat, got, err, want)
+		}
+	}
+
+	// Даже если огромный интервал обошёл разбор, поиск не зацикливается
+	rr := &RRule{Freq: FreqDaily, Interval: math.MaxInt, WeekStart: 1}
+	if next := rr.Next(now, now); !next.IsZero() {
+		t.Errorf("INTERVAL=%d: %s, ожидалось пусто", rr.Interval, next)
+	}
+}
+
+func TestRRuleConversion(t *testing.T) {
+	tbl := []struct {
+		policy string // Политика по умолчанию, как TODO_MONTHEND
+		repeat string
+		rrule  string // Пусто, если в RRULE не перевести
+	}{
+		{"", "d 1", "FREQ=DAILY"},
+		{"", "d 1 monthend skip", ""},
+		{"", "m 31", "FREQ=MONTHLY;BYMONTHDAY=31"},
+		{"", "m 15", "FREQ=MONTHLY;BYMONTHDAY=15"},
+		{"", "m 31 monthend clamp", ""},
+		{"", "y", ""},
+		{"", "y monthend skip", "FREQ=YEARLY"},
+		{"", "y 1.4,1.10", "FREQ=YEARLY;BYMONTH=4,10;BYMONTHDAY=1"},
+		{MonthEndOverflow, "m 31", ""},
+		{MonthEndOverflow, "m 15", "FREQ=MONTHLY;BYMONTHDAY=15"},
+		{MonthEndOverflow, "d 1", "FREQ=DAILY"},
+		{MonthEndSkip, "y", "FREQ=YEARLY"},
+	}
+	t.Cleanup(func() { SetMonthEnd("") })
+	for _, v := range tbl {
+		if err := SetMonthEnd(v.policy); err != nil {
+			t.Fatal(err)
+		}
+		rule, err := Parse(v.repeat)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", v.repeat, err)
+		}
+		got, err := rule.ToRRule()
+		if (err != nil) != (v.rrule == "") || got != v.rrule {
+			t.Errorf("ToRRule(%q) при %q = %q, %v, ожидалось %q", v.repeat, v.policy, got, err, v.rrule)
+		}
+	}
+}
+
+// Карточка, которую всегда отвечают нормально: сначала шаги, потом интервал растёт в ease раз
+func TestSpacedGraded(t *testing.T) {
+	rule, err := Parse("sr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{1, 3, 7, 14, 30, 75, 188, 470, 1175, 2938, 3650}
+	for i, interval := range want {
+		rule.Review = rule.Graded(GradeGood)
+		if rule.Review.Interval != interval {
+			t.Fatalf("повторение %d: интервал %d, ожидалось %d", i+1, rule.Review.Interval, interval)
+		}
+	}
+
+	// again откатывает к первому шагу и снижает множитель, но не ниже минимума
+	for i := 0; i < 10; i++ {
+		rule.Review = rule.Graded(GradeAgain)
+	}
+	if rule.Review.Interval != 1 || rule.Review.Step != 1 || rule.Review.Ease != minEase {
+		t.Fatalf("после again: %+v", rule.Review)
+	}
+}
+
+func TestScriptRule(t *testing.T) {
+	// Пример из папки rules: зарплата 25-го, с выходных на пятницу
+	if err := LoadScripts("../../../rules"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unregisterScript("payday") })
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	dates, err := NextDates(now, "20240126", "x payday", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"20240223", "20240325", "20240425", "20240524"}; strings.Join(dates, ",") != strings.Join(want, ",") {
+		t.Errorf("x payday: %v, ожидалось %v", dates, want)
+	}
+
+	// Зацикленный скрипт и дата не из будущего не дают повторений
+	scripts := map[string]string{
+		"forever": "def next(date, now):\n    for i in range(1000000000):\n        pass\n",
+		"past":    "def next(date, now):\n    return now\n",
+		"garbage": "def next(date, now):\n    return 42\n",
+		"done":    "def next(date, now):\n    return None\n",
+	}
+	for name, source := range scripts {
+		if err := RegisterScript(name, source); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { unregisterScript(name) })
+		if _, err := NextDate(now, "20240126", "x "+name); !errors.Is(err, ErrNeverFires) {
+			t.Errorf("x %s: ошибка %v, ожидалась ErrNeverFires", name, err)
+		}
+	}
+
+	// Скрипт без next, с ошибкой или с неподходящим именем не регистрируется
+	for name, source := range map[string]string{
+		"empty":  "x = 1\n",
+		"args":   "def next(date):\n    return date\n",
+		"syntax": "def next(date, now)\n",
+		"Bad!":   "def next(date, now):\n    return None\n",
+	} {
+		if err := RegisterScript(name, source); err == nil {
+			t.Errorf("RegisterScript(%q): ожидалась ошибка", name)
+		}
+	}
+	if _, err := Parse("x missing"); !errors.Is(err, ErrUnknownRule) {
+		t.Errorf("x missing: ошибка %v, ожидалась ErrUnknownRule", err)
+	}
+
+	// Проход по серии со скриптом урезан по числу дат и по времени
+	if err := RegisterScript("daily", "def next(date, now):\n    return add_days(max(date, now), 1)\n"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { unregisterScript("daily") })
+	if dates, _ := NextDates(now, "20240126", "x daily", 1000); len(dates) != scriptMaxDates {
+		t.Errorf("x daily: %d дат, ожидалось %d", len(dates), scriptMaxDates)
+	}
+	rule, err := Parse("x forever | d 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	occurrences := rule.Between(now, now, now.AddDate(0, 0, 999), 1000)
+	if elapsed := time.Since(start); elapsed > scriptBudget+2*scriptTimeout {
+		t.Errorf("x forever | d 1: проход занял %s", elapsed)
+	}
+	if len(occurrences) > scriptMaxDates {
+		t.Errorf("x forever | d 1: %d дат, ожидалось не больше %d", len(occurrences), scriptMaxDates)
+	}
+}
+
+func TestPauses(t *testing.T) {
+	now := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	pauses := []Pause{{From: "20240710", To: "20240720"}, {From: "20240801", To: "20240801"}}
+	tbl := []struct {
+		repeat string
+		date   string
+		want   string
+	}{
+		{"d 1", "20240709", "20240721"},
+		{"d 7", "20240703", "20240724"},
+		{"w 4", "20240731", "20240808"},
+		{"m 1,15", "20240701", "20240815"},
+		{"m 15 | w 3", "20240709", "20240724"},
+		{"d 1 until 20240715", "20240709", ""},
+	}
+	for _, v := range tbl {
+		rule, err := Parse(v.repeat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule.Pauses = pauses
+		got, _, err := rule.NextDateTime(now, v.date, "")
+		if v.want == "" {
+			if !errors.Is(err, ErrSeriesFinished) {
+				t.Errorf("%s от %s: ошибка %v, ожидалась ErrSeriesFinished", v.repeat, v.date, err)
+			}
+			continue
+		}
+		if err != nil || got != v.want {
+			t.Errorf("%s от %s: %s (%v), ожидалось %s", v.repeat, v.date, got, err, v.want)
+		}
+	}
+
+	for _, v := range []struct{ from, to string }{{"20240720", "20240710"}, {"2024", "20240710"}, {"20240101", "20250301"}} {
+		if _, _, err := ParsePause(v.from, v.to, time.UTC); err == nil {
+			t.Errorf("ParsePause(%s, %s): ожидалась ошибка", v.from, v.to)
+		}
+	}
+}
+
+func TestMonthEndDefault(t *testing.T) {
+	if err := SetMonthEnd(MonthEndClamp); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetMonthEnd("") })
+
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		date   string
+		repeat string
+		want   string
+	}{
+		// Политика по умолчанию меняет только правила с днём месяца
+		{"20240401", "m 31", "20240430"},
+		{"20240229", "y", "20250228"},
+		{"20240228", "d 1", "20240229"},
+		{"20240222", "d 7", "20240229"},
+		// Явный monthend у d работает как раньше
+		{"20240222", "d 7 monthend clamp", "20240228"},
+		{"20240222", "d 7 monthend skip", "20240307"},
+	}
+	for _, v := range tbl {
+		got, err := NextDate(now, v.date, v.repeat)
+		if err != nil || got != v.want {
+			t.Errorf("%s от %s: %s (%v), ожидалось %s", v.repeat, v.date, got, err, v.want)
+		}
+	}
+}
+
+func TestLeapOrigin(t *testing.T) {
+	rule, err := Parse("y monthend clamp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// Серия с 28 февраля так на 28-м и остаётся
+	if got, _, err := rule.NextDateTime(now, "20250228", ""); err != nil || got != "20260228" {
+		t.Errorf("с 28 февраля: %s (%v), ожидалось 20260228", got, err)
+	}
+
+	// Серия с 29 февраля в високосный год возвращается на 29-е
+	rule.Origin = "20240229"
+	dates := []string{}
+	date := "20250228"
+	for i := 0; i < 4; i++ {
+		date, _, err = rule.NextDateTime(now, date, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dates = append(dates, date)
+	}
+	if want := []string{"20260228", "20270228", "20280229", "20290228"}; strings.Join(dates, ",") != strings.Join(want, ",") {
+		t.Errorf("с 29 февраля: %v, ожидалось %v", dates, want)
+	}
+}
+
+func TestLeapDayClamp(t *testing.T) {
+	rule, err := Parse("d 7 monthend clamp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Задачу выполняют в срок, после переноса на 28-е шаг идёт от 29 февраля
+	rule.Origin = "20240222"
+	dates := []string{}
+	date := "20240222"
+	for i := 0; i < 3; i++ {
+		now, _ := time.Parse("20060102", date)
+		date, _, err = rule.NextDateTime(now, date, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dates = append(dates, date)
+	}
+	if want := []string{"20240228", "20240307", "20240314"}; strings.Join(dates, ",") != strings.Join(want, ",") {
+		t.Errorf("с началом серии: %v, ожидалось %v", dates, want)
+	}
+
+	// Настоящее 28 февраля в серии с 21-го не сдвигается
+	rule.Origin = "20240221"
+	if got, _, err := rule.NextDateTime(time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), "20240228", ""); err != nil || got != "20240306" {
+		t.Errorf("с 21 февраля: %s (%v), ожидалось 20240306", got, err)
+	}
+
+	// Предпросмотр считает серию от переданной даты
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	got, err := NextDates(now, "20240222", "d 7 monthend clamp", 4)
+	if want := []string{"20240228", "20240307", "20240314", "20240321"}; err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("предпросмотр: %v (%v), ожидалось %v", got, err, want)
+	}
+}
+
+func TestParseCachedBounded(t *testing.T) {
+	cacheLen := func() int {
+		rulesMu.RLock()
+		defer rulesMu.RUnlock()
+		return len(rulesCache)
+	}
+	rulesMu.Lock()
+	rulesCache = map[string]Rule{}
+	rulesMu.Unlock()
+
+	// Варианты записи одного правила занимают одну запись под каноничной строкой
+	for _, repeat := range []string{"w 3,1", "w 1,3,3", "w 3,1,1,3", "w 1,3"} {
+		rule, err := ParseCached(repeat)
+		if err != nil || rule.String() != "w 1,3" {
+			t.Fatalf("ParseCached(%q) = %q, %v", repeat, rule.String(), err)
+		}
+	}
+	if n := cacheLen(); n != 1 {
+		t.Errorf("в кэше %d записей, ожидалась одна", n)
+	}
+
+	// Каноничная запись одного правила не открывает дорогу некорректной строке
+	if _, err := ParseCached("h 4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseCached("h"); err == nil {
+		t.Error("ParseCached(\"h\") после \"h 4\" должна вернуть ошибку")
+	}
+
+	// Сколько бы разных правил ни пришло, кэш не растёт больше предела
+	for i := 1; i <= maxDayInterval; i++ {
+		for _, kind := range []string{"d %d", "d %d workdays", "d %d after"} {
+			if _, err := ParseCached(fmt.Sprintf(kind, i)); err != nil {
+				t.Fatal(err)
+			}
+			if n := cacheLen(); n > rulesCacheSize {
+				t.Fatalf("в кэше %d записей, предел %d", n, rulesCacheSize)
+			}
+		}
+	}
+}
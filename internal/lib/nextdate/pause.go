@@ -0,0 +1,51 @@
+package nextdate
+
+import (
+	"fmt"
+	"time"
+)
+
+// Самая длинная пауза в днях, дольше года задачу проще удалить
+const maxPauseDays = 366
+
+// Pause период с From по To включительно в формате 20060102, когда повторения не назначаются.
+// Например, отпуск: повторения на эти дни пропускаются, серия идёт дальше как обычно.
+type Pause struct {
+	From string
+	To   string
+}
+
+// ParsePause проверяет даты паузы и возвращает её начало и конец в часовом поясе loc
+func ParsePause(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("20060102", from, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("начало паузы должно быть в формате ГГГГММДД: %s", from)
+	}
+	end, err := time.ParseInLocation("20060102", to, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("конец паузы должен быть в формате ГГГГММДД: %s", to)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("конец паузы раньше начала")
+	}
+	if end.After(start.AddDate(0, 0, maxPauseDays)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("пауза не может быть длиннее %d дней", maxPauseDays)
+	}
+	return start, end, nil
+}
+
+// Contains проверяет, попадает ли день date в паузу
+func (p Pause) Contains(date time.Time) bool {
+	day := date.Format("20060102")
+	return day >= p.From && day <= p.To
+}
+
+// paused проверяет, попадает ли дата в одну из пауз правила
+func (r Rule) paused(date time.Time) bool {
+	for _, pause := range r.Pauses {
+		if pause.Contains(date) {
+			return true
+		}
+	}
+	return false
+}
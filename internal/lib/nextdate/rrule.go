@@ -0,0 +1,752 @@
+package nextdate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Правило в формате RFC 5545, например FREQ=WEEKLY;BYDAY=MO,WE;INTERVAL=2
+const KindRRule Kind = "rrule"
+
+// Частоты RRULE
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// Насколько далеко вперёд ищем дату, если правило так ничего и не выдало
+const rruleHorizonYears = 100
+
+// Дни недели RRULE, индекс совпадает с нашей нумерацией (1 - понедельник)
+var rruleWeekdays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// WeekdayNum день недели с необязательным порядковым номером: 2TU, -1FR или просто MO
+type WeekdayNum struct {
+	N       int // 0 - каждый такой день, иначе номер в месяце/году, отрицательный с конца
+	Weekday int // 1 - понедельник, 7 - воскресенье
+}
+
+func (wn WeekdayNum) String() string {
+	if wn.N == 0 {
+		return rruleWeekdays[wn.Weekday]
+	}
+	return strconv.Itoa(wn.N) + rruleWeekdays[wn.Weekday]
+}
+
+// RRule разобранное правило RFC 5545.
+// Поддерживаются FREQ, INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, BYSETPOS, COUNT, UNTIL и WKST.
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	Count      int       // 0 - без ограничения
+	Until      time.Time // Нулевое значение - без ограничения
+	WeekStart  int       // 1 - понедельник по умолчанию
+}
+
+// Наибольший INTERVAL для каждой частоты, как у правил d, w, m и y
+var rruleMaxInterval = map[string]int{
+	FreqDaily:   maxDayInterval,
+	FreqWeekly:  maxWeekInterval,
+	FreqMonthly: maxMonthInterval,
+	FreqYearly:  maxYearInterval,
+}
+
+// isRRule проверяет, похожа ли строка на RRULE
+func isRRule(repeat string) bool {
+	upper := strings.ToUpper(repeat)
+	return strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=")
+}
+
+// parseRRule разбирает строку вида [RRULE:]FREQ=...;KEY=VALUE;...
+func parseRRule(repeat string) (*RRule, error) {
+	value := strings.TrimSpace(repeat)
+	if strings.HasPrefix(strings.ToUpper(value), "RRULE:") {
+		value = value[len("RRULE:"):]
+	}
+
+	rr := &RRule{Interval: 1, WeekStart: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(key)
+		if !ok || val == "" {
+			return nil, fmt.Errorf("пустое значение %s", key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("параметр %s указан дважды", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			rr.Freq = strings.ToUpper(val)
+			switch rr.Freq {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+			default:
+				return nil, fmt.Errorf("неподдерживаемая частота %s", val)
+			}
+		case "INTERVAL":
+			rr.Interval, err = strconv.Atoi(val)
+			if err != nil || rr.Interval < 1 {
+				return nil, fmt.Errorf("некорректный INTERVAL %s", val)
+			}
+		case "COUNT":
+			rr.Count, err = strconv.Atoi(val)
+			if err != nil || rr.Count < 1 {
+				return nil, fmt.Errorf("некорректный COUNT %s", val)
+			}
+		case "UNTIL":
+			// Время нас не интересует, берём только дату
+			if len(val) < 8 {
+				return nil, fmt.Errorf("некорректный UNTIL %s", val)
+			}
+			rr.Until, err = time.Parse("20060102", val[:8])
+			if err != nil {
+				return nil, fmt.Errorf("некорректный UNTIL %s", val)
+			}
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				wn, err := parseWeekdayNum(item)
+				if err != nil {
+					return nil, err
+				}
+				rr.ByDay = append(rr.ByDay, wn)
+			}
+		case "BYMONTHDAY":
+			rr.ByMonthDay, err = parseSignedList(val, 31)
+			if err != nil {
+				return nil, fmt.Errorf("некорректный BYMONTHDAY: %s", err)
+			}
+		case "BYMONTH":
+			rr.ByMonth, err = parseList(val, 1, 12)
+			if err != nil {
+				return nil, fmt.Errorf("некорректный BYMONTH: %s", err)
+			}
+		case "BYSETPOS":
+			rr.BySetPos, err = parseSignedList(val, 366)
+			if err != nil {
+				return nil, fmt.Errorf("некорректный BYSETPOS: %s", err)
+			}
+		case "WKST":
+			wn, err := parseWeekdayNum(val)
+			if err != nil || wn.N != 0 {
+				return nil, fmt.Errorf("некорректный WKST %s", val)
+			}
+			rr.WeekStart = wn.Weekday
+		default:
+			return nil, fmt.Errorf("неподдерживаемый параметр %s", key)
+		}
+	}
+
+	if rr.Freq == "" {
+		return nil, fmt.Errorf("не указан FREQ")
+	}
+	if rr.Count > 0 && !rr.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT и UNTIL нельзя указывать вместе")
+	}
+	// Интервал ограничен так же, как у наших правил: иначе период убегает за горизонт поиска,
+	// а на огромных значениях номер периода переполняется
+	if limit := rruleMaxInterval[rr.Freq]; rr.Interval > limit {
+		return nil, fmt.Errorf("INTERVAL для %s вне диапазона 1..%d: %d", rr.Freq, limit, rr.Interval)
+	}
+	// Порядковые номера у BYDAY имеют смысл только для месяцев и лет
+	for _, wn := range rr.ByDay {
+		if wn.N != 0 && rr.Freq != FreqMonthly && rr.Freq != FreqYearly {
+			return nil, fmt.Errorf("порядковый номер в BYDAY допустим только для MONTHLY и YEARLY")
+		}
+	}
+
+	return rr, nil
+}
+
+// neverFires ищет сочетания параметров, при которых серия пуста при любом начале:
+// порядковый номер BYDAY больше, чем таких дней в периоде, или BYSETPOS дальше, чем бывает дат в периоде.
+// Возвращает причину или пустую строку.
+func (rr *RRule) neverFires() string {
+	// Порядковые номера в годовом правиле без месяцев считаются по году, иначе по месяцу
+	maxN := 5
+	if rr.Freq == FreqYearly && len(rr.ByMonth) == 0 && len(rr.ByMonthDay) == 0 {
+		maxN = 53
+	}
+	if len(rr.ByDay) > 0 {
+		possible := false
+		for _, wn := range rr.ByDay {
+			possible = possible || (wn.N >= -maxN && wn.N <= maxN)
+		}
+		if !possible {
+			return fmt.Sprintf("в периоде не бывает больше %d одинаковых дней недели", maxN)
+		}
+	}
+
+	if len(rr.BySetPos) > 0 {
+		size := rr.maxSetSize()
+		for _, pos := range rr.BySetPos {
+			if pos >= -size && pos <= size {
+				return ""
+			}
+		}
+		return fmt.Sprintf("в периоде не бывает больше %d дат, BYSETPOS %s их не выберет", size, joinList(rr.BySetPos))
+	}
+	return ""
+}
+
+// maxSetSize оценивает сверху, сколько дат бывает в одном периоде до BYSETPOS
+func (rr *RRule) maxSetSize() int {
+	// Дни недели в месяце: без номера до 5 в месяц, с номером - один
+	weekdaysInMonth := 0
+	for _, wn := range rr.ByDay {
+		if wn.N == 0 {
+			weekdaysInMonth += 5
+		} else {
+			weekdaysInMonth++
+		}
+	}
+	// Сколько дат бывает в одном месяце
+	perMonth := 1
+	switch {
+	case len(rr.ByDay) > 0 && len(rr.ByMonthDay) > 0:
+		perMonth = min(weekdaysInMonth, len(rr.ByMonthDay), 31)
+	case len(rr.ByDay) > 0:
+		perMonth = min(weekdaysInMonth, 31)
+	case len(rr.ByMonthDay) > 0:
+		perMonth = min(len(rr.ByMonthDay), 31)
+	}
+
+	switch rr.Freq {
+	case FreqWeekly:
+		if len(rr.ByDay) == 0 {
+			return 1
+		}
+		weekdays := []int{}
+		for _, wn := range rr.ByDay {
+			if !search(wn.Weekday, weekdays) {
+				weekdays = append(weekdays, wn.Weekday)
+			}
+		}
+		return len(weekdays)
+	case FreqMonthly:
+		return perMonth
+	case FreqYearly:
+		if len(rr.ByMonth) == 0 && len(rr.ByMonthDay) == 0 && len(rr.ByDay) == 0 {
+			// Тот же день, что у начала серии
+			return 1
+		}
+		if len(rr.ByMonth) == 0 && len(rr.ByMonthDay) == 0 {
+			// Дни недели по всему году: без номера до 53 в год
+			perYear := 0
+			for _, wn := range rr.ByDay {
+				if wn.N == 0 {
+					perYear += 53
+				} else {
+					perYear++
+				}
+			}
+			return min(perYear, 366)
+		}
+		months := len(rr.ByMonth)
+		if months == 0 {
+			months = 12
+		}
+		return min(months*perMonth, 366)
+	}
+	return 1
+}
+
+// parseWeekdayNum разбирает элемент BYDAY: MO, 2TU, -1FR
+func parseWeekdayNum(item string) (WeekdayNum, error) {
+	item = strings.ToUpper(strings.TrimSpace(item))
+	if len(item) < 2 {
+		return WeekdayNum{}, fmt.Errorf("некорректный день недели %q", item)
+	}
+
+	wn := WeekdayNum{}
+	for i, name := range rruleWeekdays {
+		if name != "" && strings.HasSuffix(item, name) {
+			wn.Weekday = i
+		}
+	}
+	if wn.Weekday == 0 {
+		return WeekdayNum{}, fmt.Errorf("некорректный день недели %q", item)
+	}
+
+	if prefix := item[:len(item)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, fmt.Errorf("некорректный номер дня недели %q", item)
+		}
+		wn.N = n
+	}
+
+	return wn, nil
+}
+
+// parseSignedList разбирает список ненулевых чисел в пределах -max..max
+func parseSignedList(value string, max int) ([]int, error) {
+	list, err := parseList(value, -max, max)
+	if err != nil {
+		return nil, err
+	}
+	if search(0, list) {
+		return nil, fmt.Errorf("0 недопустим")
+	}
+	return list, nil
+}
+
+// String собирает правило в каноничном порядке параметров
+func (rr *RRule) String() string {
+	parts := []string{"FREQ=" + rr.Freq}
+	if rr.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rr.Interval))
+	}
+	if len(rr.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinList(rr.ByMonth))
+	}
+	if len(rr.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinList(rr.ByMonthDay))
+	}
+	if len(rr.ByDay) > 0 {
+		days := make([]string, 0, len(rr.ByDay))
+		for _, wn := range rr.ByDay {
+			days = append(days, wn.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(rr.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinList(rr.BySetPos))
+	}
+	if rr.WeekStart != 1 {
+		parts = append(parts, "WKST="+rruleWeekdays[rr.WeekStart])
+	}
+	if rr.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(rr.Count))
+	}
+	if !rr.Until.IsZero() {
+		parts = append(parts, "UNTIL="+rr.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next возвращает первое вхождение серии, начатой в start, строго позже after.
+// Если серия закончилась или ничего не нашлось, вернётся нулевое time.Time.
+func (rr *RRule) Next(start, after time.Time) time.Time {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	horizon := after.AddDate(rruleHorizonYears, 0, 0)
+
+	// Периодов до горизонта не больше, чем дней, так цикл конечен при любом INTERVAL
+	maxPeriods := int(horizon.Sub(start).Hours()/24) + 1
+	count := 0
+	for period := 0; period <= maxPeriods; period++ {
+		periodStart := rr.periodStart(start, period*rr.Interval)
+		if periodStart.After(horizon) {
+			return time.Time{}
+		}
+		if !rr.Until.IsZero() && afterDay(periodStart, rr.Until) {
+			return time.Time{}
+		}
+
+		for _, candidate := range rr.expand(start, periodStart) {
+			// Вхождения до начала серии не считаются
+			if candidate.Before(start) {
+				continue
+			}
+			if !rr.Until.IsZero() && afterDay(candidate, rr.Until) {
+				return time.Time{}
+			}
+			count++
+			if rr.Count > 0 && count > rr.Count {
+				return time.Time{}
+			}
+			if candidate.After(after) {
+				return candidate
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// periodStart вычисляет начало периода, отстоящего от периода start на offset единиц FREQ
+func (rr *RRule) periodStart(start time.Time, offset int) time.Time {
+	switch rr.Freq {
+	case FreqWeekly:
+		// Откатываемся к началу недели с учётом WKST
+		shift := (isoWeekday(start) - rr.WeekStart + 7) % 7
+		return start.AddDate(0, 0, offset*7-shift)
+	case FreqMonthly:
+		return time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, start.Location())
+	case FreqYearly:
+		return time.Date(start.Year()+offset, 1, 1, 0, 0, 0, 0, start.Location())
+	}
+	return start.AddDate(0, 0, offset)
+}
+
+// expand возвращает отсортированные даты-кандидаты одного периода
+func (rr *RRule) expand(start, periodStart time.Time) []time.Time {
+	var candidates []time.Time
+
+	switch rr.Freq {
+	case FreqDaily:
+		candidates = []time.Time{periodStart}
+
+	case FreqWeekly:
+		for i := 0; i < 7; i++ {
+			day := periodStart.AddDate(0, 0, i)
+			if len(rr.ByDay) == 0 && isoWeekday(day) != isoWeekday(start) {
+				continue
+			}
+			candidates = append(candidates, day)
+		}
+
+	case FreqMonthly:
+		candidates = rr.expandMonth(start, periodStart.Year(), periodStart.Month())
+
+	case FreqYearly:
+		year := periodStart.Year()
+		switch {
+		case len(rr.ByMonth) == 0 && len(rr.ByMonthDay) == 0 && len(rr.ByDay) > 0:
+			// Порядковые номера BYDAY считаются от начала года
+			candidates = expandWeekdays(rr.ByDay,
+				time.Date(year, 1, 1, 0, 0, 0, 0, start.Location()),
+				time.Date(year, 12, 31, 0, 0, 0, 0, start.Location()))
+		case len(rr.ByMonth) == 0 && len(rr.ByMonthDay) == 0:
+			// Без уточнений - тот же день и месяц, что и у начала серии
+			day := time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+			if day.Day() == start.Day() {
+				candidates = []time.Time{day}
+			}
+		default:
+			for month := time.January; month <= time.December; month++ {
+				candidates = append(candidates, rr.expandMonth(start, year, month)...)
+			}
+		}
+	}
+
+	// Ограничивающие фильтры
+	filtered := candidates[:0]
+	for _, day := range candidates {
+		if len(rr.ByMonth) > 0 && !search(int(day.Month()), rr.ByMonth) {
+			continue
+		}
+		if len(rr.ByMonthDay) > 0 && !matchMonthDay(day, rr.ByMonthDay) {
+			continue
+		}
+		if len(rr.ByDay) > 0 && (rr.Freq == FreqDaily || rr.Freq == FreqWeekly) && !matchWeekday(day, rr.ByDay) {
+			continue
+		}
+		filtered = append(filtered, day)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Before(filtered[j]) })
+	if len(rr.BySetPos) == 0 {
+		return filtered
+	}
+
+	// BYSETPOS выбирает позиции из набора кандидатов периода
+	selected := []time.Time{}
+	for i, day := range filtered {
+		if search(i+1, rr.BySetPos) || search(i-len(filtered), rr.BySetPos) {
+			selected = append(selected, day)
+		}
+	}
+	return selected
+}
+
+// expandMonth раскрывает кандидатов в пределах одного месяца
+func (rr *RRule) expandMonth(start time.Time, year int, month time.Month) []time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, start.Location())
+	last := first.AddDate(0, 1, -1)
+
+	if len(rr.ByDay) > 0 {
+		// BYMONTHDAY, если есть, отфильтрует их позже
+		return expandWeekdays(rr.ByDay, first, last)
+	}
+
+	if len(rr.ByMonthDay) > 0 {
+		days := []time.Time{}
+		for _, d := range rr.ByMonthDay {
+			if day, ok := monthDay(year, month, d, start.Location()); ok {
+				days = append(days, day)
+			}
+		}
+		return days
+	}
+
+	// Без уточнений - тот же день месяца, что и у начала серии, если он в месяце есть
+	if start.Day() > last.Day() {
+		return nil
+	}
+	return []time.Time{time.Date(year, month, start.Day(), 0, 0, 0, 0, start.Location())}
+}
+
+// expandWeekdays раскрывает BYDAY в отрезке [first, last] с учётом порядковых номеров
+func expandWeekdays(byDay []WeekdayNum, first, last time.Time) []time.Time {
+	days := []time.Time{}
+	for _, wn := range byDay {
+		// Все подходящие дни недели в отрезке
+		matched := []time.Time{}
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			if isoWeekday(day) == wn.Weekday {
+				matched = append(matched, day)
+			}
+		}
+
+		switch {
+		case wn.N == 0:
+			days = append(days, matched...)
+		case wn.N > 0 && wn.N <= len(matched):
+			days = append(days, matched[wn.N-1])
+		case wn.N < 0 && -wn.N <= len(matched):
+			days = append(days, matched[len(matched)+wn.N])
+		}
+	}
+	return days
+}
+
+// monthDay возвращает день месяца, отрицательные значения считаются с конца
+func monthDay(year int, month time.Month, day int, loc *time.Location) (time.Time, bool) {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day < 0 {
+		day = lastDay + day + 1
+	}
+	if day < 1 || day > lastDay {
+		return time.Time{}, false
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, loc), true
+}
+
+// matchMonthDay проверяет, попадает ли дата в список дней месяца
+func matchMonthDay(day time.Time, monthDays []int) bool {
+	lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, d := range monthDays {
+		if d == day.Day() || (d < 0 && lastDay+d+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWeekday проверяет день недели без учёта порядковых номеров
+func matchWeekday(day time.Time, byDay []WeekdayNum) bool {
+	for _, wn := range byDay {
+		if wn.Weekday == isoWeekday(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// isoWeekday возвращает день недели, где 1 - понедельник, 7 - воскресенье
+func isoWeekday(t time.Time) int {
+	day := int(t.Weekday())
+	if day == 0 {
+		return 7
+	}
+	return day
+}
+
+// rrule возвращает копию RRULE с условиями окончания, поднятыми в Rule
+func (r Rule) rrule() *RRule {
+	rr := *r.RRule
+	rr.Count, rr.Until = r.Count, r.Until
+	return &rr
+}
+
+// ToRRule переводит правило в запись RFC 5545
+func (r Rule) ToRRule() (string, error) {
+	if r.Kind == KindUnion {
+		return "", errUnion("RRULE")
+	}
+	// Переносов и рабочих дней в RFC 5545 нет, время без DTSTART тоже не выразить
+	if r.Roll != "" || r.Workdays {
+		return "", fmt.Errorf("правило с переносом на рабочие дни нельзя перевести в RRULE")
+	}
+	if r.At != "" {
+		return "", fmt.Errorf("правило со временем нельзя перевести в RRULE")
+	}
+	if r.AfterDone {
+		return "", fmt.Errorf("правило от момента выполнения нельзя перевести в RRULE")
+	}
+	// RFC 5545 несуществующие даты просто пропускает, другие политики в нём не выразить
+	if !r.rruleMonthEnd() {
+		return "", fmt.Errorf("правило с monthend %s нельзя перевести в RRULE", r.monthEnd())
+	}
+
+	rr := &RRule{Interval: 1, WeekStart: 1, Count: r.Count, Until: r.Until}
+	switch r.Kind {
+	case KindRRule:
+		return r.rrule().String(), nil
+	case KindDay:
+		rr.Freq, rr.Interval = FreqDaily, r.Interval
+	case KindYear:
+		rr.Freq, rr.Interval = FreqYearly, max(r.Interval, 1)
+		if len(r.Weeks) > 0 {
+			return "", fmt.Errorf("правило y с неделями года нельзя перевести в RRULE")
+		}
+		if len(r.YearDates) > 0 {
+			days, months, ok := yearDatesGrid(r.YearDates)
+			if !ok {
+				return "", fmt.Errorf("в RRULE даты года должны быть одними и теми же днями в каждом из месяцев")
+			}
+			rr.ByMonthDay, rr.ByMonth = days, months
+		}
+	case KindWeek:
+		rr.Freq, rr.Interval = FreqWeekly, max(r.Interval, 1)
+		for _, day := range r.Weekdays {
+			rr.ByDay = append(rr.ByDay, WeekdayNum{Weekday: day})
+		}
+	case KindMonth:
+		rr.Freq, rr.ByMonthDay, rr.ByMonth, rr.Interval = FreqMonthly, r.MonthDays, r.Months, max(r.Interval, 1)
+	case KindMonthWeekday:
+		rr.Freq, rr.ByDay, rr.ByMonth = FreqMonthly, r.NthWeekdays, r.Months
+	default:
+		return "", fmt.Errorf("правило %s нельзя перевести в RRULE", r.Kind)
+	}
+	return rr.String(), nil
+}
+
+// rruleMonthEnd проверяет, что действующая политика конца месяца совпадает с RFC 5545.
+// Важна она только там, где несуществующий день правда может попасться.
+func (r Rule) rruleMonthEnd() bool {
+	switch r.Kind {
+	case KindDay:
+		// RRULE не пропускает и не сдвигает 29 февраля у ежедневных правил
+		return r.MonthEnd == "" || r.MonthEnd == MonthEndOverflow
+	case KindMonth:
+		return r.monthEnd() == MonthEndSkip || monthDaysAlways(r.MonthDays, r.Months)
+	case KindYear:
+		if len(r.YearDates) > 0 {
+			return r.monthEnd() == MonthEndSkip || yearDatesAlways(r.YearDates)
+		}
+		// Дата задачи может оказаться 29 февраля, а такой год RFC 5545 пропускает
+		return r.monthEnd() == MonthEndSkip
+	}
+	return true
+}
+
+// Native пробует перевести RRULE или cron в эквивалентное правило d/w/m/mw/y.
+// Второе значение false, если точного эквивалента нет.
+func (r Rule) Native() (Rule, bool) {
+	var native Rule
+	var ok bool
+	switch r.Kind {
+	case KindRRule:
+		native, ok = r.RRule.native()
+	case KindCron:
+		native, ok = r.Cron.native()
+	default:
+		return r, true
+	}
+	if !ok {
+		return Rule{}, false
+	}
+	native.Count, native.Until, native.Roll, native.AfterDone = r.Count, r.Until, r.Roll, r.AfterDone
+	// У cron время своё, у RRULE его задаёт модификатор at
+	if r.At != "" {
+		native.At = r.At
+	}
+	return native, true
+}
+
+// native переводит само RRULE без условий окончания
+func (rr *RRule) native() (Rule, bool) {
+	if rr.Count > 0 || !rr.Until.IsZero() || len(rr.BySetPos) > 0 || rr.WeekStart != 1 {
+		return Rule{}, false
+	}
+
+	switch rr.Freq {
+	case FreqDaily:
+		if len(rr.ByDay) == 0 && len(rr.ByMonthDay) == 0 && len(rr.ByMonth) == 0 && rr.Interval <= maxDayInterval {
+			return Rule{Kind: KindDay, Interval: rr.Interval}, true
+		}
+		// Каждый день, но только по определённым дням недели
+		if rr.Interval == 1 && len(rr.ByDay) > 0 && len(rr.ByMonthDay) == 0 && len(rr.ByMonth) == 0 {
+			return weeklyNative(rr.ByDay)
+		}
+	case FreqWeekly:
+		if rr.Interval <= maxWeekInterval && len(rr.ByDay) > 0 && len(rr.ByMonthDay) == 0 && len(rr.ByMonth) == 0 {
+			rule, ok := weeklyNative(rr.ByDay)
+			if rr.Interval > 1 {
+				rule.Interval = rr.Interval
+			}
+			return rule, ok
+		}
+	case FreqMonthly:
+		// Только N-ные дни недели месяца без других уточнений
+		if rr.Interval == 1 && len(rr.ByDay) > 0 && len(rr.ByMonthDay) == 0 {
+			for _, wn := range rr.ByDay {
+				if wn.N == 0 || wn.N < -5 || wn.N > 5 {
+					return Rule{}, false
+				}
+			}
+			return Rule{Kind: KindMonthWeekday, NthWeekdays: rr.ByDay, Months: rr.ByMonth}, true
+		}
+		// Шаг и список месяцев у m вместе не бывают
+		if len(rr.ByDay) == 0 && len(rr.ByMonthDay) > 0 && (rr.Interval == 1 || len(rr.ByMonth) == 0) && rr.Interval <= maxMonthInterval {
+			for _, d := range rr.ByMonthDay {
+				if d < -2 {
+					return Rule{}, false
+				}
+			}
+			rule := Rule{Kind: KindMonth, MonthDays: rr.ByMonthDay, Months: rr.ByMonth}
+			if rr.Interval > 1 {
+				rule.Interval = rr.Interval
+			}
+			return rule, true
+		}
+	case FreqYearly:
+		// Конкретные даты года, несуществующие RFC 5545 пропускает
+		if rr.Interval == 1 && len(rr.ByDay) == 0 && len(rr.ByMonthDay) > 0 && len(rr.ByMonth) > 0 {
+			var dates []YearDate
+			for _, month := range rr.ByMonth {
+				for _, day := range rr.ByMonthDay {
+					if day < -2 {
+						return Rule{}, false
+					}
+					dates = append(dates, YearDate{Day: day, Month: month})
+				}
+			}
+			sortYearDates(dates)
+			rule := Rule{Kind: KindYear, YearDates: dates}
+			if !yearDatesAlways(dates) && rule.monthEnd() != MonthEndSkip {
+				rule.MonthEnd = MonthEndSkip
+			}
+			return rule, true
+		}
+		if len(rr.ByDay) == 0 && len(rr.ByMonthDay) == 0 && len(rr.ByMonth) == 0 && rr.Interval <= maxYearInterval {
+			rule := Rule{Kind: KindYear}
+			if rr.Interval > 1 {
+				rule.Interval = rr.Interval
+			}
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// weeklyNative собирает правило w из BYDAY без порядковых номеров
+func weeklyNative(byDay []WeekdayNum) (Rule, bool) {
+	days := []int{}
+	for _, wn := range byDay {
+		if wn.N != 0 {
+			return Rule{}, false
+		}
+		if !search(wn.Weekday, days) {
+			days = append(days, wn.Weekday)
+		}
+	}
+	sort.Ints(days)
+	return Rule{Kind: KindWeek, Weekdays: days}, true
+}
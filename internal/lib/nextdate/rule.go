@@ -0,0 +1,874 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind описывает тип правила повторения
+type Kind string
+
+const (
+	KindDay   Kind = "d" // Каждые N дней
+	KindWeek  Kind = "w" // По дням недели
+	KindMonth Kind = "m" // По дням месяца
+	KindYear  Kind = "y" // Раз в год
+
+	KindMonthWeekday Kind = "mw" // По N-ному дню недели месяца: первый понедельник, последняя пятница
+	KindHour         Kind = "h"  // Каждые N часов
+)
+
+// Максимально допустимый интервал для правила d, по ТЗ
+const maxDayInterval = 400
+
+// Максимально допустимый интервал для правила h, дальше проще пользоваться d
+const maxHourInterval = 24
+
+// Максимально допустимые интервалы для y и m, дальше задача скорее забудется, чем повторится
+const (
+	maxYearInterval  = 100
+	maxMonthInterval = 120
+	maxWeekInterval  = 52
+)
+
+// Типовые ошибки разбора правил, сверять через errors.Is
+var (
+	ErrEmptyRepeat  = errors.New("обнаружена некорректная строка в атрибуте repeat")
+	ErrUnknownRule  = errors.New("неверный формат repeat")
+	ErrMissingValue = errors.New("не передано обязательное значение правила")
+	ErrInvalidValue = errors.New("некорректное значение правила")
+
+	// Правило записано верно, но ни одной даты по нему не бывает, например m 30 2
+	ErrNeverFires = errors.New("правило никогда не сработает")
+
+	// Не ошибка разбора, а признак того, что повторений больше не будет
+	ErrSeriesFinished = errors.New("серия повторений завершена")
+)
+
+// ParseError уточняет, в каком правиле и на каком значении споткнулся разбор
+type ParseError struct {
+	Repeat string // Исходная строка
+	Err    error  // Одна из типовых ошибок выше
+	Msg    string // Подробности для человека
+}
+
+func (e *ParseError) Error() string {
+	if e.Msg == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Err, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Rule скомпилированное правило повторения.
+// Разбирается один раз через Parse, дальше переиспользуется сколько угодно раз.
+type Rule struct {
+	Kind      Kind
+	Interval  int   // Для d и h количество дней или часов, для y, m и w шаг в годах, месяцах и неделях от даты задачи
+	Weekdays  []int // Для w, 1 - понедельник, 7 - воскресенье
+	MonthDays []int // Для m, 1..31, а так же -1 и -2 с конца месяца
+	Months    []int // Для m и mw, необязательный список месяцев 1..12
+	RRule     *RRule
+	Cron      *Cron
+
+	// Для mw, порядковый номер дня недели в месяце 1..5 или -1..-5 с конца
+	NthWeekdays []WeekdayNum
+
+	// Для y, список дат года или ISO-недели 1..53, дни недели тогда в Weekdays
+	YearDates []YearDate
+	Weeks     []int
+
+	// Условия окончания серии, нулевые значения - без ограничения
+	Until time.Time // Последняя допустимая дата
+	Count int       // Сколько всего повторений, считая дату задачи
+
+	At       string // Время повторения ЧЧ:ММ, пустое - правило работает целыми днями
+	Roll     string // Перенос с выходных и праздников: next, prev или nearest
+	Workdays bool   // Для d, считать только рабочие дни
+
+	// Что делать с днём, которого нет в месяце: overflow, clamp или skip.
+	// Пустое значение - политика по умолчанию, см. SetMonthEnd.
+	MonthEnd string
+
+	// Следующая дата считается от момента выполнения, а не от даты задачи.
+	// Для повторения "через 3 дня после того, как сделал".
+	AfterDone bool
+
+	// Дата начала серии 20060102, задаётся отдельно для каждой задачи.
+	// По ней d и y с monthend clamp знают, что задача на 28 февраля на самом деле с 29-го.
+	Origin string
+
+	// Пропускаемые даты в формате 20060102.
+	// В строку правила не входят, задаются отдельно для каждой задачи.
+	Except []string
+
+	// Паузы, например отпуск: повторения внутри них пропускаются так же, как Except.
+	// Тоже задаются отдельно, общие для всех задач и свои у каждой.
+	Pauses []Pause
+
+	// Для sr, интервалы в днях для первых повторений
+	Steps []int
+	// Для sr, состояние карточки и оценка выполнения, от которой считается следующая дата.
+	// Как и Except, задаются отдельно для каждой задачи.
+	Review Review
+	Grade  string
+
+	// Для объединения, правила, из которых берётся самая ранняя дата
+	Rules []Rule
+
+	// Для x, имя зарегистрированного скрипта
+	Script string
+}
+
+// Parse разбирает строку repeat в правило.
+// После основного правила могут идти модификаторы, например "d 7 until 20251231".
+func Parse(repeat string) (Rule, error) {
+	fields := strings.Fields(repeat)
+	if len(fields) == 0 {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrEmptyRepeat}
+	}
+	if strings.Contains(repeat, ruleSeparator) {
+		return parseUnion(repeat)
+	}
+
+	args, mods := splitModifiers(fields[1:])
+
+	var rule Rule
+	if isCron(fields[0]) {
+		// Поля cron разделены пробелами, слово cron перед ними необязательно
+		expr := args
+		if fields[0] != string(KindCron) {
+			expr = append([]string{fields[0]}, args...)
+		}
+		c, err := parseCron(expr)
+		if err != nil {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: err.Error()}
+		}
+		if !c.fires() {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrNeverFires, Msg: fmt.Sprintf("в месяцах %s нет дней %s", joinList(c.Months), joinList(c.Days))}
+		}
+		rule = Rule{Kind: KindCron, Cron: c}
+	} else if isRRule(fields[0]) {
+		// RRULE пишется одним словом без пробелов, разбираем его отдельно
+		if len(args) > 0 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "RRULE не должно содержать пробелов"}
+		}
+		rr, err := parseRRule(fields[0])
+		if err != nil {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: err.Error()}
+		}
+		if len(rr.ByMonthDay) > 0 && !monthDaysExist(rr.ByMonthDay, rr.ByMonth) {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrNeverFires, Msg: fmt.Sprintf("в месяцах %s нет дней %s", joinList(rr.ByMonth), joinList(rr.ByMonthDay))}
+		}
+		if msg := rr.neverFires(); msg != "" {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrNeverFires, Msg: msg}
+		}
+		// Условия окончания у нас общие для всех правил, поднимем их наверх
+		rule = Rule{Kind: KindRRule, RRule: rr, Count: rr.Count, Until: rr.Until}
+		rr.Count, rr.Until = 0, time.Time{}
+	} else {
+		var err error
+		rule, err = parseNative(repeat, Kind(fields[0]), args)
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+
+	if err := rule.applyModifiers(mods); err != nil {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: err.Error()}
+	}
+
+	// Несуществующие дни бывают проблемой, только если их пропускать
+	if rule.Kind == KindMonth && rule.monthEnd() == MonthEndSkip && !monthDaysExist(rule.MonthDays, rule.Months) {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrNeverFires, Msg: fmt.Sprintf("в месяцах %s нет дней %s", joinList(rule.Months), joinList(rule.MonthDays))}
+	}
+	if len(rule.YearDates) > 0 && rule.monthEnd() == MonthEndSkip && !yearDatesExist(rule.YearDates) {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrNeverFires, Msg: fmt.Sprintf("таких дат не бывает: %s", rule.yearBase())}
+	}
+	if altCalendars[rule.Kind] != nil && rule.monthEnd() == MonthEndSkip && rule.MonthDays[0] > altMaxDays(rule.Kind, rule.Months[0]) {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrNeverFires, Msg: fmt.Sprintf("в месяце %d не бывает %d дней", rule.Months[0], rule.MonthDays[0])}
+	}
+
+	return rule, nil
+}
+
+// parseNative разбирает правила d, w, m, mw, y, x и правила других календарей
+func parseNative(repeat string, kind Kind, args []string) (Rule, error) {
+	rule := Rule{Kind: kind}
+
+	switch rule.Kind {
+	case KindDay:
+		if len(args) == 0 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "не указано количество дней"}
+		}
+		if len(args) > 1 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "лишние значения для правила d"}
+		}
+		interval, err := strconv.Atoi(args[0])
+		if err != nil {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("значение дня передано некорректно: %s", args[0])}
+		}
+		// По ТЗ, интервал не больше 400 дней
+		if interval < 1 || interval > maxDayInterval {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("интервал дней вне диапазона 1..%d: %d", maxDayInterval, interval)}
+		}
+		rule.Interval = interval
+
+	case KindHour:
+		if len(args) != 1 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "правило h принимает только количество часов"}
+		}
+		interval, err := strconv.Atoi(args[0])
+		if err != nil || interval < 1 || interval > maxHourInterval {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("интервал часов вне диапазона 1..%d: %s", maxHourInterval, args[0])}
+		}
+		rule.Interval = interval
+
+	case KindYear:
+		// Без значения - каждый год, иначе раз в N лет от даты задачи,
+		// список дат "y 1.4,1.10" или дни ISO-недель "y w1,27 1"
+		if len(args) > 0 && strings.HasPrefix(args[0], "w") {
+			if len(args) != 2 {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "для недель года в правиле y нужны номера недель и дни недели"}
+			}
+			weeks, err := parseList(args[0][1:], 1, 53)
+			if err != nil {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("некорректный номер недели: %s", err)}
+			}
+			days, err := parseList(args[1], 1, 7)
+			if err != nil {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("некорректный номер дня недели: %s", err)}
+			}
+			rule.Weeks, rule.Weekdays = weeks, days
+			break
+		}
+		if len(args) > 1 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "лишние значения для правила y"}
+		}
+		if len(args) == 1 && strings.Contains(args[0], ".") {
+			dates, err := parseYearDates(args[0])
+			if err != nil {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: err.Error()}
+			}
+			rule.YearDates = dates
+			break
+		}
+		if len(args) == 1 {
+			interval, err := strconv.Atoi(args[0])
+			if err != nil || interval < 1 || interval > maxYearInterval {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("интервал лет вне диапазона 1..%d: %s", maxYearInterval, args[0])}
+			}
+			rule.Interval = interval
+		}
+
+	case KindWeek:
+		if len(args) == 0 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "при передаче правила w, пришел пустой день недели"}
+		}
+		if len(args) > 2 || (len(args) == 2 && !strings.HasPrefix(args[1], "/")) {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "лишние значения для правила w"}
+		}
+		days, err := parseList(args[0], 1, 7)
+		if err != nil {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("некорректный номер дня недели: %s", err)}
+		}
+		rule.Weekdays = days
+
+		// Необязательный шаг в неделях от недели даты задачи: w 1 /2
+		if len(args) == 2 {
+			interval, err := strconv.Atoi(args[1][1:])
+			if err != nil || interval < 1 || interval > maxWeekInterval {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("интервал недель вне диапазона 1..%d: %s", maxWeekInterval, args[1])}
+			}
+			rule.Interval = interval
+		}
+
+	case KindMonth:
+		if len(args) == 0 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "при передаче правила m, пришел пустой день месяца"}
+		}
+		if len(args) > 2 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "лишние значения для правила m"}
+		}
+		days, err := parseList(args[0], -2, 31)
+		if err != nil || search(0, days) {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("некорректный день месяца: %s", args[0])}
+		}
+		rule.MonthDays = days
+
+		// Необязательный шаг в месяцах от даты задачи: m 15 /3
+		if len(args) > 1 && strings.HasPrefix(args[1], "/") {
+			interval, err := strconv.Atoi(args[1][1:])
+			if err != nil || interval < 1 || interval > maxMonthInterval {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("интервал месяцев вне диапазона 1..%d: %s", maxMonthInterval, args[1])}
+			}
+			rule.Interval = interval
+		} else if len(args) > 1 { // Или последовательность месяцев
+			months, err := parseList(args[1], 1, 12)
+			if err != nil {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("числовое значение месяца некорректно: %s", err)}
+			}
+			rule.Months = months
+		}
+
+	case KindMonthWeekday:
+		if len(args) == 0 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "при передаче правила mw, не указан день недели"}
+		}
+		if len(args) > 2 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "лишние значения для правила mw"}
+		}
+		days, err := parseNthWeekdays(args[0])
+		if err != nil {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: err.Error()}
+		}
+		rule.NthWeekdays = days
+
+		if len(args) > 1 {
+			months, err := parseList(args[1], 1, 12)
+			if err != nil {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("числовое значение месяца некорректно: %s", err)}
+			}
+			rule.Months = months
+		}
+
+	case KindSpaced:
+		if len(args) > 1 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "лишние значения для правила sr"}
+		}
+		rule.Steps = defaultSpacedSteps
+		if len(args) == 1 {
+			steps, err := parseList(args[0], 1, maxDayInterval)
+			if err != nil {
+				return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: fmt.Sprintf("некорректный шаг повторения: %s", err)}
+			}
+			rule.Steps = steps
+		}
+
+	case KindChinese, KindHebrew, KindHijri:
+		return parseAltCalendar(repeat, rule.Kind, args)
+
+	case KindScript:
+		return parseScript(repeat, args)
+
+	default:
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrUnknownRule}
+	}
+
+	return rule, nil
+}
+
+// Next вычисляет ближайшую дату повторения для задачи с датой date относительно now.
+// Если серия закончилась по until или count, вернётся ErrSeriesFinished.
+// Если подходящей даты найти не удалось, вернётся ErrNeverFires.
+func (r Rule) Next(now, date time.Time) (time.Time, error) {
+	if r.Kind == KindUnion {
+		return r.nextUnion(now, date)
+	}
+	now, date = r.start(now, date)
+	next := r.next(now, date)
+	// Пропущенные даты остаются в серии, просто на них не останавливаемся
+	for !next.IsZero() && r.skipped(next) {
+		next = r.next(next, next)
+	}
+	if next.IsZero() {
+		// RRULE само обрывает серию по UNTIL и COUNT, других причин у него почти не бывает
+		if r.Kind == KindRRule && (r.Count > 0 || !r.Until.IsZero()) {
+			return time.Time{}, ErrSeriesFinished
+		}
+		// Пустую дату отдавать нельзя, её запишут в БД как следующую
+		return time.Time{}, ErrNeverFires
+	}
+
+	// until ограничивает по дню, время внутри последнего дня не важно
+	if !r.Until.IsZero() && afterDay(next, r.Until) {
+		return time.Time{}, ErrSeriesFinished
+	}
+	if r.Count > 0 && r.index(date, next) > r.Count {
+		return time.Time{}, ErrSeriesFinished
+	}
+
+	return next, nil
+}
+
+// start возвращает now и date, от которых считается следующая дата.
+// Для after и sr серия начинается заново с момента выполнения now.
+func (r Rule) start(now, date time.Time) (time.Time, time.Time) {
+	if !r.AfterDone && r.Kind != KindSpaced {
+		return now, date
+	}
+	// Часовое правило и cron считаем от самого момента, остальные от начала дня
+	if r.clocked() {
+		return now, now
+	}
+	return truncateDay(now), truncateDay(now)
+}
+
+// skipped проверяет, попадает ли дата в список пропускаемых или в паузу
+func (r Rule) skipped(date time.Time) bool {
+	if r.paused(date) {
+		return true
+	}
+	day := date.Format("20060102")
+	for _, except := range r.Except {
+		if except == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Remaining считает, сколько повторений останется у серии,
+// если задачу с датой date перенести на next. Сама next тоже считается.
+func (r Rule) Remaining(date, next time.Time) int {
+	if r.Count == 0 {
+		return 0
+	}
+	// Для after каждый перенос это ровно одно повторение, даже если дата сдвинулась назад
+	if r.AfterDone {
+		if next.Equal(date) {
+			return r.Count
+		}
+		return r.Count - 1
+	}
+	return r.Count - r.index(date, next) + 1
+}
+
+// index возвращает порядковый номер next в серии, начатой в date (она сама первая).
+// Дальше Count не считаем, этого достаточно для проверки окончания.
+func (r Rule) index(date, next time.Time) int {
+	k := 1
+	for cur := date; cur.Before(next) && k <= r.Count; k++ {
+		cur = r.next(cur, cur)
+		if cur.IsZero() {
+			break
+		}
+	}
+	return k
+}
+
+// next вычисляет дату без учёта условий окончания, но с переносом на рабочий день и временем
+func (r Rule) next(now, date time.Time) time.Time {
+	// Часовое правило и cron и так работают с полным временем
+	if r.At == "" || r.clocked() {
+		return r.nextDay(now, date)
+	}
+
+	clock, _ := time.Parse("15:04", r.At)
+	day := truncateDay(date)
+	after := now
+	if withClock(day, clock).After(now) {
+		after = withClock(day, clock)
+	}
+
+	// Сдвигаем now назад на время повторения, тогда сегодняшнее ещё не прошедшее
+	// повторение останется кандидатом для дневных правил
+	shifted := now.Add(-time.Duration(clock.Hour())*time.Hour - time.Duration(clock.Minute())*time.Minute)
+	cur := r.nextDay(shifted, day)
+	for i := 0; i < maxRollDays && !cur.IsZero(); i++ {
+		cur = truncateDay(cur)
+		if withClock(cur, clock).After(after) {
+			return withClock(cur, clock)
+		}
+		cur = r.nextDay(cur, cur)
+	}
+	return time.Time{}
+}
+
+// nextDay вычисляет дату по правилу с переносом на рабочий день
+func (r Rule) nextDay(now, date time.Time) time.Time {
+	raw := r.occurrence(now, date)
+	if r.Roll == "" {
+		return raw
+	}
+
+	after := now
+	if date.After(now) {
+		after = date
+	}
+
+	// После переноса назад дата может оказаться в прошлом, тогда берём следующую
+	cal := currentCalendar()
+	for i := 0; i < maxRollDays && !raw.IsZero(); i++ {
+		rolled := cal.Roll(raw, r.Roll)
+		if rolled.After(after) {
+			return rolled
+		}
+		raw = r.occurrence(raw, raw)
+	}
+	return time.Time{}
+}
+
+// Максимальное количество дней в каждом месяце, с учётом високосного февраля
+var maxMonthDays = []int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// monthDaysExist проверяет, что хотя бы один день из days бывает хотя бы в одном из months.
+// Отрицательные дни считаются с конца месяца. Пустой months - любой месяц.
+func monthDaysExist(days, months []int) bool {
+	if len(months) == 0 {
+		months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	}
+	for _, month := range months {
+		for _, day := range days {
+			if day <= maxMonthDays[month] && -day <= maxMonthDays[month] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// monthDaysAlways проверяет, что все дни из days бывают в каждом из months в любой год
+func monthDaysAlways(days, months []int) bool {
+	if len(months) == 0 {
+		months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	}
+	for _, month := range months {
+		for _, day := range days {
+			// 2001 год невисокосный, в нём месяцы самые короткие
+			if day > daysIn(2001, time.Month(month)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// clocked проверяет, что время повторений задаёт само правило, а не задача или at
+func (r Rule) clocked() bool {
+	return r.Kind == KindHour || r.Kind == KindCron
+}
+
+// HasTime проверяет, что у повторений есть время, а не только дата
+func (r Rule) HasTime() bool {
+	if r.At != "" || r.clocked() {
+		return true
+	}
+	for _, sub := range r.Rules {
+		if sub.HasTime() {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateDay отбрасывает время, оставляя полночь того же дня
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// weekStart возвращает понедельник недели, в которую попадает t
+func weekStart(t time.Time) time.Time {
+	return truncateDay(t).AddDate(0, 0, 1-isoWeekday(t))
+}
+
+// daysBetween считает календарные дни от from до to, переводы часов не влияют
+func daysBetween(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// afterDay проверяет, что календарный день t позже дня day.
+// Сравниваем по датам, а не по моментам: until задан без часового пояса.
+func afterDay(t, day time.Time) bool {
+	return t.Format("20060102") > day.Format("20060102")
+}
+
+// withClock выставляет дню время из clock
+func withClock(day, clock time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location())
+}
+
+// occurrence вычисляет дату строго по правилу, без переносов и ограничений
+func (r Rule) occurrence(now, date time.Time) time.Time {
+	switch r.Kind {
+	case KindHour:
+		step := time.Duration(r.Interval) * time.Hour
+		if date.After(now) {
+			return date.Add(step)
+		}
+		// Сразу перескакиваем на нужное количество шагов, без цикла по часам
+		return date.Add((now.Sub(date)/step + 1) * step)
+
+	case KindDay:
+		return r.leapDay(now, date, r.dayOccurrence(now, r.dayBase(date)))
+
+	case KindYear:
+		if len(r.YearDates) > 0 {
+			return r.nextYearDates(now, date)
+		}
+		if len(r.Weeks) > 0 {
+			return r.nextISOWeek(now, date)
+		}
+		step := max(r.Interval, 1)
+		if policy := r.monthEnd(); policy != MonthEndOverflow {
+			return nextYearDate(now, date, step, policy, r.leapOrigin())
+		}
+		if date.After(now) {
+			return date.AddDate(step, 0, 0)
+		}
+
+		for !date.After(now) {
+			date = date.AddDate(step, 0, 0)
+		}
+		return date
+
+	case KindWeek:
+		// Узнаем откуда нам производить отсчёт
+		dateStart := now
+		if date.After(now) {
+			dateStart = date
+		}
+
+		// Идём по дням вперёд начиная со следующего за dateStart, максимум step недель.
+		// Чётность недель считаем от недели даты задачи, чтобы она не плыла со временем.
+		step := max(r.Interval, 1)
+		anchor := weekStart(date)
+		for j := 1; j <= 7*step; j++ {
+			day := dateStart.AddDate(0, 0, j)
+			if !search(isoWeekday(day), r.Weekdays) {
+				continue
+			}
+			if step > 1 && daysBetween(anchor, day)/7%step != 0 {
+				continue
+			}
+			return day
+		}
+		return time.Time{}
+
+	case KindMonth:
+		dateStart := now
+		if date.After(now) {
+			dateStart = date
+		}
+		// С шагом месяцы отсчитываются от месяца даты задачи
+		if r.Interval > 1 {
+			return findNextDateEvery(dateStart, date, r.MonthDays, r.Interval, r.monthEnd())
+		}
+		return findNextDate(dateStart, r.MonthDays, r.Months, r.monthEnd())
+
+	case KindMonthWeekday:
+		dateStart := now
+		if date.After(now) {
+			dateStart = date
+		}
+		return findNextWeekday(dateStart, r.NthWeekdays, r.Months)
+
+	case KindChinese, KindHebrew, KindHijri:
+		return r.altOccurrence(now, date)
+
+	case KindScript:
+		return r.scriptOccurrence(now, date)
+
+	case KindSpaced:
+		// Интервал зависит от оценки выполнения, считаем его от дня выполнения
+		return truncateDay(now).AddDate(0, 0, r.Graded(r.Grade).Interval)
+
+	case KindCron:
+		after := now
+		if date.After(now) {
+			after = date
+		}
+		return r.Cron.Next(after)
+
+	case KindRRule:
+		// date для RRULE это начало серии
+		after := now
+		if date.After(now) {
+			after = date
+		}
+		// Вместо COUNT и UNTIL самого RRULE работают общие условия окончания
+		return r.RRule.Next(date, after)
+	}
+
+	return time.Time{}
+}
+
+// dayOccurrence вычисляет дату правила d без учёта политики для 29 февраля
+func (r Rule) dayOccurrence(now, date time.Time) time.Time {
+	// Рабочие дни считаем отдельно, без поблажек для каждодневных задач
+	if r.Workdays {
+		cal := currentCalendar()
+		if date.After(now) {
+			return cal.AddWorkdays(date, r.Interval)
+		}
+		for !date.IsZero() && !date.After(now) {
+			date = cal.AddWorkdays(date, r.Interval)
+		}
+		return date
+	}
+
+	// Проверим на каждодневность
+	// Если дата < чем сейчас, перенесём на сегодня
+	if r.Interval == 1 && date.Before(now) {
+		return now
+	}
+
+	// Необходимо отдельное условие для событий
+	// Когда date уже больше чем now
+	if date.After(now) {
+		return date.AddDate(0, 0, r.Interval)
+	}
+
+	// Добавляем интервал, пока дата не перерастёт now
+	for !date.After(now) {
+		date = date.AddDate(0, 0, r.Interval)
+	}
+	return date
+}
+
+// String возвращает каноничную запись правила,
+// одинаковые по смыслу правила дают одинаковую строку
+func (r Rule) String() string {
+	if r.Kind == KindUnion {
+		return r.unionString()
+	}
+	if r.Kind == KindRRule {
+		return r.rrule().String() + r.modifiers()
+	}
+	if r.Kind == KindCron {
+		return string(r.Kind) + " " + r.Cron.String() + r.modifiers()
+	}
+	return r.base() + r.modifiers()
+}
+
+// base возвращает запись правила без модификаторов
+func (r Rule) base() string {
+	switch r.Kind {
+	case KindDay, KindHour:
+		return fmt.Sprintf("%s %d", r.Kind, r.Interval)
+	case KindWeek:
+		if r.Interval > 1 {
+			return fmt.Sprintf("%s %s /%d", r.Kind, joinList(r.Weekdays), r.Interval)
+		}
+		return fmt.Sprintf("%s %s", r.Kind, joinList(r.Weekdays))
+	case KindYear:
+		if len(r.YearDates) > 0 || len(r.Weeks) > 0 {
+			return r.yearBase()
+		}
+		if r.Interval > 1 {
+			return fmt.Sprintf("%s %d", r.Kind, r.Interval)
+		}
+	case KindMonth:
+		if r.Interval > 1 {
+			return fmt.Sprintf("%s %s /%d", r.Kind, joinList(r.MonthDays), r.Interval)
+		}
+		if len(r.Months) == 0 {
+			return fmt.Sprintf("%s %s", r.Kind, joinList(r.MonthDays))
+		}
+		return fmt.Sprintf("%s %s %s", r.Kind, joinList(r.MonthDays), joinList(r.Months))
+	case KindSpaced:
+		return r.spacedBase()
+	case KindChinese, KindHebrew, KindHijri:
+		return r.altBase()
+	case KindScript:
+		return fmt.Sprintf("%s %s", r.Kind, r.Script)
+	case KindMonthWeekday:
+		days := make([]string, 0, len(r.NthWeekdays))
+		for _, wn := range r.NthWeekdays {
+			days = append(days, fmt.Sprintf("%d:%d", wn.N, wn.Weekday))
+		}
+		if len(r.Months) == 0 {
+			return fmt.Sprintf("%s %s", r.Kind, strings.Join(days, ","))
+		}
+		return fmt.Sprintf("%s %s %s", r.Kind, strings.Join(days, ","), joinList(r.Months))
+	}
+	return string(r.Kind)
+}
+
+// parseList разбирает список чисел через запятую с проверкой диапазона.
+// Возвращает отсортированный список без повторов.
+func parseList(value string, min, max int) ([]int, error) {
+	list := []int{}
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q не число", item)
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("%d вне диапазона %d..%d", n, min, max)
+		}
+		if !search(n, list) {
+			list = append(list, n)
+		}
+	}
+
+	// Положительные по возрастанию, отрицательные (с конца) после них: 1,15,-1,-2
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i] > 0) != (list[j] > 0) {
+			return list[i] > 0
+		}
+		if list[i] > 0 {
+			return list[i] < list[j]
+		}
+		return list[i] > list[j]
+	})
+	return list, nil
+}
+
+// parseNthWeekdays разбирает список вида 1:1,-1:5, где сначала номер в месяце, потом день недели
+func parseNthWeekdays(value string) ([]WeekdayNum, error) {
+	days := []WeekdayNum{}
+	for _, item := range strings.Split(value, ",") {
+		nStr, dayStr, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("ожидается номер:день недели, пришло %q", item)
+		}
+		n, err := strconv.Atoi(nStr)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return nil, fmt.Errorf("номер дня недели в месяце должен быть 1..5 или -1..-5, пришло %q", item)
+		}
+		day, err := strconv.Atoi(dayStr)
+		if err != nil || day < 1 || day > 7 {
+			return nil, fmt.Errorf("некорректный номер дня недели %q", item)
+		}
+
+		wn := WeekdayNum{N: n, Weekday: day}
+		if !containsWeekdayNum(days, wn) {
+			days = append(days, wn)
+		}
+	}
+
+	// Сначала с начала месяца, потом с конца, внутри по дню недели
+	sort.Slice(days, func(i, j int) bool {
+		a, b := days[i], days[j]
+		if a.N != b.N {
+			if (a.N > 0) != (b.N > 0) {
+				return a.N > 0
+			}
+			if a.N > 0 {
+				return a.N < b.N
+			}
+			return a.N > b.N
+		}
+		return a.Weekday < b.Weekday
+	})
+	return days, nil
+}
+
+// containsWeekdayNum аналог search для дней недели с номером
+func containsWeekdayNum(days []WeekdayNum, target WeekdayNum) bool {
+	for _, wn := range days {
+		if wn == target {
+			return true
+		}
+	}
+	return false
+}
+
+// joinList собирает список чисел обратно в строку через запятую
+func joinList(list []int) string {
+	items := make([]string, 0, len(list))
+	for _, n := range list {
+		items = append(items, strconv.Itoa(n))
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,71 @@
+package nextdate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseString(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		canon  string
+	}{
+		{"d 1", "d 1"},
+		{"d 400", "d 400"},
+		{"h 4", "h 4"},
+		{"y", "y"},
+		{"w 7", "w 7"},
+		{"w 3,1,1,7", "w 1,3,7"},
+		{"m 15", "m 15"},
+		{"m -1,15,1,-2", "m 1,15,-1,-2"},
+		{"m 1,15 12,1,3", "m 1,15 1,3,12"},
+	}
+	for _, v := range tbl {
+		rule, err := Parse(v.repeat)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", v.repeat, err)
+			continue
+		}
+		if got := rule.String(); got != v.canon {
+			t.Errorf("Parse(%q).String() = %q, ожидалось %q", v.repeat, got, v.canon)
+		}
+
+		// Каноничная запись разбирается в то же самое правило
+		again, err := Parse(rule.String())
+		if err != nil || !reflect.DeepEqual(again, rule) {
+			t.Errorf("Parse(%q) = %+v, %v, ожидалось %+v", rule.String(), again, err, rule)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		err    error
+	}{
+		{"", ErrEmptyRepeat},
+		{"k 1", ErrUnknownRule},
+		{"d", ErrMissingValue},
+		{"w", ErrMissingValue},
+		{"m", ErrMissingValue},
+		{"d 0", ErrInvalidValue},
+		{"d 401", ErrInvalidValue},
+		{"h", ErrMissingValue},
+		{"h 25", ErrInvalidValue},
+		{"w 8", ErrInvalidValue},
+		{"m 32", ErrInvalidValue},
+		{"m -3", ErrInvalidValue},
+		{"m 1 13", ErrInvalidValue},
+	}
+	for _, v := range tbl {
+		_, err := Parse(v.repeat)
+		if !errors.Is(err, v.err) {
+			t.Errorf("Parse(%q): %v, ожидалось %v", v.repeat, err, v.err)
+		}
+		var perr *ParseError
+		if err != nil && !errors.As(err, &perr) {
+			t.Errorf("Parse(%q): ошибка %T, ожидалась *ParseError", v.repeat, err)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package nextdate
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Правило, которое считает следующую дату скриптом на Starlark: "x payday".
+// Скрипт регистрируется под именем и определяет функцию next(date, now),
+// которая получает дату задачи и сегодняшнюю дату строками ГГГГММДД
+// и возвращает следующую дату строго позже обеих или None, если дат больше нет.
+const KindScript Kind = "x"
+
+// Ограничения на один вызов скрипта, чтобы зациклившееся правило не повесило сервер
+const (
+	scriptMaxSteps = 1_000_000
+	scriptTimeout  = 100 * time.Millisecond
+)
+
+// Ограничения на один проход по серии со скриптом (NextDates, Between): каждый вызов
+// может идти до scriptTimeout, поэтому дат меньше, а общее время прохода ограничено
+const (
+	scriptMaxDates = 20
+	scriptBudget   = 300 * time.Millisecond
+)
+
+// Имя скрипта попадает в строку repeat, поэтому без пробелов и спецсимволов
+var scriptName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+var (
+	scriptsMu sync.RWMutex
+	scripts   = map[string]*starlark.Function{}
+)
+
+// Встроенные функции для скриптов, даты везде строками ГГГГММДД
+var scriptBuiltins = starlark.StringDict{
+	"make_date":     starlark.NewBuiltin("make_date", scriptDate),
+	"parse":         starlark.NewBuiltin("parse", scriptParse),
+	"weekday":       starlark.NewBuiltin("weekday", scriptWeekday),
+	"add_days":      starlark.NewBuiltin("add_days", scriptAddDays),
+	"days_in_month": starlark.NewBuiltin("days_in_month", scriptDaysInMonth),
+}
+
+// RegisterScript компилирует скрипт source и регистрирует его под именем name.
+// Скрипт с тем же именем заменяется.
+func RegisterScript(name, source string) error {
+	if !scriptName.MatchString(name) {
+		return fmt.Errorf("имя правила-скрипта может состоять только из a-z, 0-9, _ и -: %s", name)
+	}
+
+	thread, stop := scriptThread(name)
+	defer stop()
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, name+".star", source, scriptBuiltins)
+	if err != nil {
+		return fmt.Errorf("правило-скрипт %s: %s", name, err)
+	}
+	next, ok := globals["next"].(*starlark.Function)
+	if !ok || next.NumParams() != 2 {
+		return fmt.Errorf("правило-скрипт %s должно определять функцию next(date, now)", name)
+	}
+	// Замороженный скрипт можно вызывать из нескольких запросов сразу
+	globals.Freeze()
+
+	scriptsMu.Lock()
+	scripts[name] = next
+	scriptsMu.Unlock()
+	return nil
+}
+
+// LoadScripts регистрирует все скрипты *.star из папки dir, имя правила - имя файла
+func LoadScripts(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.star"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".star")
+		if err := RegisterScript(name, string(source)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unregisterScript убирает скрипт из реестра, нужен тестам, чтобы не оставлять свои скрипты другим
+func unregisterScript(name string) {
+	scriptsMu.Lock()
+	delete(scripts, name)
+	scriptsMu.Unlock()
+}
+
+func lookupScript(name string) *starlark.Function {
+	scriptsMu.RLock()
+	defer scriptsMu.RUnlock()
+	return scripts[name]
+}
+
+// scriptThread создаёт поток с ограничением по шагам и по времени.
+// stop нужно вызвать после выполнения, чтобы остановить таймер.
+func scriptThread(name string) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{Name: name}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	timer := time.AfterFunc(scriptTimeout, func() {
+		thread.Cancel("превышено время выполнения")
+	})
+	return thread, func() { timer.Stop() }
+}
+
+// usesScript проверяет, считает ли правило или одно из правил объединения даты скриптом
+func (r Rule) usesScript() bool {
+	if r.Kind == KindScript {
+		return true
+	}
+	for _, rule := range r.Rules {
+		if rule.usesScript() {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptLimits урезает число дат limit для правила со скриптом и возвращает момент,
+// после которого проход по серии надо остановить. Для правил без скриптов момент нулевой.
+func (r Rule) scriptLimits(limit int) (int, time.Time) {
+	if !r.usesScript() {
+		return limit, time.Time{}
+	}
+	return min(limit, scriptMaxDates), time.Now().Add(scriptBudget)
+}
+
+// scriptExpired проверяет, вышло ли время прохода по серии со скриптом
+func scriptExpired(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// parseScript разбирает правило x
+func parseScript(repeat string, args []string) (Rule, error) {
+	if len(args) != 1 {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "правило x принимает имя скрипта"}
+	}
+	if lookupScript(args[0]) == nil {
+		return Rule{}, &ParseError{Repeat: repeat, Err: ErrUnknownRule, Msg: fmt.Sprintf("правило-скрипт %s не зарегистрировано", args[0])}
+	}
+	return Rule{Kind: KindScript, Script: args[0]}, nil
+}
+
+// scriptOccurrence вызывает скрипт правила. Ошибка скрипта или неподходящая дата
+// значат, что повторений больше нет, а сама ошибка пишется в лог.
+func (r Rule) scriptOccurrence(now, date time.Time) time.Time {
+	next := lookupScript(r.Script)
+	if next == nil {
+		return time.Time{}
+	}
+
+	thread, stop := scriptThread(r.Script)
+	defer stop()
+
+	args := starlark.Tuple{starlark.String(date.Format("20060102")), starlark.String(now.Format("20060102"))}
+	value, err := starlark.Call(thread, next, args, nil)
+	if err != nil {
+		log.Printf("правило-скрипт %s: %s", r.Script, err)
+		return time.Time{}
+	}
+	if value == starlark.None {
+		return time.Time{}
+	}
+
+	result, ok := starlark.AsString(value)
+	parsed, err := time.ParseInLocation("20060102", result, now.Location())
+	if !ok || err != nil {
+		log.Printf("правило-скрипт %s вернуло не дату ГГГГММДД: %s", r.Script, value)
+		return time.Time{}
+	}
+	// Иначе NextDates и календарь ходили бы по кругу
+	if !afterDay(parsed, now) || !afterDay(parsed, date) {
+		log.Printf("правило-скрипт %s вернуло %s, не позже %s", r.Script, result, now.Format("20060102"))
+		return time.Time{}
+	}
+	return parsed
+}
+
+// make_date(y, m, d) собирает дату, лишние дни и месяцы переходят дальше: make_date(2024, 13, 1) - 20250101
+func scriptDate(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var year, month, day int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &year, &month, &day); err != nil {
+		return nil, err
+	}
+	return starlark.String(time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format("20060102")), nil
+}
+
+// parse(d) раскладывает дату на (год, месяц, день)
+func scriptParse(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	day, err := scriptDay(b, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.Tuple{starlark.MakeInt(day.Year()), starlark.MakeInt(int(day.Month())), starlark.MakeInt(day.Day())}, nil
+}
+
+// weekday(d) день недели, 1 - понедельник, 7 - воскресенье
+func scriptWeekday(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	day, err := scriptDay(b, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.MakeInt(isoWeekday(day)), nil
+}
+
+// add_days(d, n) сдвигает дату на n дней, n может быть отрицательным
+func scriptAddDays(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value string
+	var n int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &value, &n); err != nil {
+		return nil, err
+	}
+	day, err := time.Parse("20060102", value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: дата должна быть в формате ГГГГММДД: %s", b.Name(), value)
+	}
+	return starlark.String(day.AddDate(0, 0, n).Format("20060102")), nil
+}
+
+// days_in_month(y, m) количество дней в месяце
+func scriptDaysInMonth(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var year, month int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &year, &month); err != nil {
+		return nil, err
+	}
+	if month < 1 || month > 12 {
+		return nil, fmt.Errorf("%s: месяц вне диапазона 1..12: %d", b.Name(), month)
+	}
+	return starlark.MakeInt(daysIn(year, time.Month(month))), nil
+}
+
+// scriptDay разбирает единственный аргумент-дату
+func scriptDay(b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (time.Time, error) {
+	var value string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &value); err != nil {
+		return time.Time{}, err
+	}
+	day, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: дата должна быть в формате ГГГГММДД: %s", b.Name(), value)
+	}
+	return day, nil
+}
@@ -0,0 +1,100 @@
+package nextdate
+
+import (
+	"math"
+	"slices"
+)
+
+// Интервальное повторение для карточек: каждое выполнение отодвигает следующее всё дальше.
+// Пишется как "sr" или со своими шагами "sr 1,3,7,14,30".
+const KindSpaced Kind = "sr"
+
+// Оценки выполнения, как в карточках: забыл, с трудом, нормально, легко
+const (
+	GradeAgain = "again"
+	GradeHard  = "hard"
+	GradeGood  = "good"
+	GradeEasy  = "easy"
+)
+
+// Шаги по умолчанию, в днях между повторениями
+var defaultSpacedSteps = []int{1, 3, 7, 14, 30}
+
+// Коэффициенты роста интервала после шагов
+const (
+	defaultEase = 2.5  // Начальный множитель
+	minEase     = 1.3  // Ниже множитель не опускается, иначе карточка застрянет
+	easeStep    = 0.15 // Насколько hard и easy меняют множитель
+	againEase   = 0.2  // Насколько again уменьшает множитель
+	hardFactor  = 1.2  // hard растит интервал медленнее
+	easyFactor  = 1.3  // easy растит интервал быстрее
+)
+
+// Дальше 10 лет откладывать повторение смысла нет
+const maxSpacedInterval = 3650
+
+// Review состояние карточки интервального повторения.
+// Хранится отдельно для каждой задачи, нулевое значение - новая карточка.
+type Review struct {
+	Step     int     // Сколько шагов пройдено, индекс в Steps
+	Ease     float64 // Множитель интервала после шагов, 0 - по умолчанию
+	Interval int     // Последний интервал в днях
+}
+
+// IsGrade проверяет, что оценка выполнения известна
+func IsGrade(grade string) bool {
+	return grade == GradeAgain || grade == GradeHard || grade == GradeGood || grade == GradeEasy
+}
+
+// Graded возвращает состояние карточки после выполнения с оценкой grade.
+// Пустая оценка считается нормальной. Interval нового состояния - через сколько дней следующее повторение.
+func (r Rule) Graded(grade string) Review {
+	review := r.Review
+	if review.Ease == 0 {
+		review.Ease = defaultEase
+	}
+
+	switch grade {
+	case GradeAgain:
+		// Забыл - начинаем шаги сначала, первый шаг это и есть повторение после again
+		review.Step = 1
+		review.Ease = math.Max(minEase, review.Ease-againEase)
+		review.Interval = r.Steps[0]
+		return review
+	case GradeHard:
+		// С трудом - шаг не засчитываем, интервал растёт чуть-чуть
+		review.Ease = math.Max(minEase, review.Ease-easeStep)
+		review.Interval = min(max(r.Steps[0], int(math.Round(float64(review.Interval)*hardFactor))), maxSpacedInterval)
+		return review
+	}
+
+	// good и easy переводят карточку на следующий шаг, после шагов интервал умножается
+	interval := int(math.Round(float64(review.Interval) * review.Ease))
+	if review.Step < len(r.Steps) {
+		interval = r.Steps[review.Step]
+	}
+	review.Step++
+	if grade == GradeEasy {
+		review.Ease += easeStep
+		interval = int(math.Round(float64(interval) * easyFactor))
+	}
+	review.Interval = min(max(interval, 1), maxSpacedInterval)
+	return review
+}
+
+// advance продвигает карточку так, будто повторение выполнено с оценкой Grade.
+// Нужно для предпросмотра нескольких дат подряд, у остальных правил ничего не меняется.
+func (r Rule) advance() Rule {
+	if r.Kind == KindSpaced {
+		r.Review, r.Grade = r.Graded(r.Grade), ""
+	}
+	return r
+}
+
+// spacedBase возвращает запись правила sr, шаги по умолчанию не пишутся
+func (r Rule) spacedBase() string {
+	if slices.Equal(r.Steps, defaultSpacedSteps) {
+		return string(r.Kind)
+	}
+	return string(r.Kind) + " " + joinList(r.Steps)
+}
@@ -0,0 +1,132 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Объединение нескольких правил: "w 1 | m -1" - по понедельникам и в последний день месяца.
+// Следующая дата - самая ранняя из дат отдельных правил.
+const KindUnion Kind = "union"
+
+// Разделитель правил в объединении
+const ruleSeparator = "|"
+
+// SplitRules делит строку repeat на отдельные правила объединения.
+// Для одиночного правила вернётся оно само.
+func SplitRules(repeat string) []string {
+	parts := strings.Split(repeat, ruleSeparator)
+	rules := make([]string, 0, len(parts))
+	for _, part := range parts {
+		rules = append(rules, strings.TrimSpace(part))
+	}
+	return rules
+}
+
+// JoinRules собирает несколько правил в одну строку repeat
+func JoinRules(rules []string) string {
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		parts = append(parts, strings.TrimSpace(rule))
+	}
+	return strings.Join(parts, " "+ruleSeparator+" ")
+}
+
+// parseUnion разбирает объединение, каждое правило со своими модификаторами
+func parseUnion(repeat string) (Rule, error) {
+	rule := Rule{Kind: KindUnion}
+	for _, part := range SplitRules(repeat) {
+		if part == "" {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrMissingValue, Msg: "пустое правило в объединении"}
+		}
+		sub, err := Parse(part)
+		if err != nil {
+			return Rule{}, err
+		}
+		// У карточки одно состояние на задачу, делить его между правилами нельзя
+		if sub.Kind == KindSpaced {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "интервальное повторение нельзя объединять с другими правилами"}
+		}
+		// Оставшееся число повторений хранится одно на задачу, у объединения его не посчитать
+		if sub.Count > 0 {
+			return Rule{}, &ParseError{Repeat: repeat, Err: ErrInvalidValue, Msg: "count нельзя использовать в объединении правил, используйте until"}
+		}
+		rule.Rules = append(rule.Rules, sub)
+	}
+	return rule, nil
+}
+
+// nextUnion ищет самую раннюю дату среди правил объединения.
+// Серия заканчивается, когда закончились все правила.
+func (r Rule) nextUnion(now, date time.Time) (time.Time, error) {
+	var next time.Time
+	finished := true
+	for _, sub := range r.Rules {
+		sub.Except = r.Except
+		sub.Pauses = r.Pauses
+		cur, err := sub.Next(now, date)
+		if errors.Is(err, ErrSeriesFinished) {
+			continue
+		}
+		finished = false
+		if err != nil {
+			continue
+		}
+		if next.IsZero() || cur.Before(next) {
+			next = cur
+		}
+	}
+
+	switch {
+	case !next.IsZero():
+		return next, nil
+	case finished:
+		return time.Time{}, ErrSeriesFinished
+	}
+	return time.Time{}, ErrNeverFires
+}
+
+// unionString собирает каноничную запись объединения
+func (r Rule) unionString() string {
+	parts := make([]string, 0, len(r.Rules))
+	for _, sub := range r.Rules {
+		parts = append(parts, sub.String())
+	}
+	return JoinRules(parts)
+}
+
+// describeUnion перечисляет описания правил: "по понедельникам, а также последнего числа каждого месяца"
+func (r Rule) describeUnion(lang string) (string, error) {
+	parts := make([]string, 0, len(r.Rules))
+	for _, sub := range r.Rules {
+		text, err := sub.Describe(lang)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, text)
+	}
+	if lang == LangEn {
+		return strings.Join(parts, ", and also "), nil
+	}
+	return strings.Join(parts, ", а также "), nil
+}
+
+// anchorUnion раздаёт время задачи правилам объединения, у которых своего времени нет
+func (r Rule) anchorUnion(clock string) Rule {
+	rules := make([]Rule, 0, len(r.Rules))
+	for _, sub := range r.Rules {
+		if sub.At == "" && !sub.clocked() {
+			sub.At = clock
+		}
+		rules = append(rules, sub)
+	}
+	r.Rules = rules
+	return r
+}
+
+// errUnion общая ошибка для переводов, которые объединение не поддерживает
+func errUnion(format string) error {
+	return fmt.Errorf("объединение правил нельзя перевести в %s", format)
+}
@@ -0,0 +1,239 @@
+package nextdate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// YearDate день года для правила y: "y 1.4,1.10" - 1 апреля и 1 октября.
+// Day как и в правиле m: 1..31, а так же -1 и -2 с конца месяца.
+type YearDate struct {
+	Day   int
+	Month int
+}
+
+// Сколько лет вперёд ищем дату правила y со списком. 53-я неделя бывает раз в 5-6 лет,
+// 29 февраля при пропуске - раз в 8 лет, но на всякий случай берём полный цикл календаря.
+const yearSearchYears = 400
+
+// parseYearDates разбирает список дат "1.4,-1.2,15.10"
+func parseYearDates(value string) ([]YearDate, error) {
+	dates := []YearDate{}
+	for _, item := range strings.Split(value, ",") {
+		dayValue, monthValue, ok := strings.Cut(item, ".")
+		if !ok {
+			return nil, fmt.Errorf("дата должна быть в формате день.месяц: %s", item)
+		}
+		day, err := strconv.Atoi(dayValue)
+		if err != nil || day < -2 || day == 0 || day > 31 {
+			return nil, fmt.Errorf("некорректный день месяца: %s", item)
+		}
+		month, err := strconv.Atoi(monthValue)
+		if err != nil || month < 1 || month > 12 {
+			return nil, fmt.Errorf("числовое значение месяца некорректно: %s", item)
+		}
+
+		date := YearDate{Day: day, Month: month}
+		if !containsYearDate(dates, date) {
+			dates = append(dates, date)
+		}
+	}
+
+	sortYearDates(dates)
+	return dates, nil
+}
+
+// sortYearDates упорядочивает даты по порядку в году, дни с конца месяца после обычных
+func sortYearDates(dates []YearDate) {
+	sort.Slice(dates, func(i, j int) bool {
+		if dates[i].Month != dates[j].Month {
+			return dates[i].Month < dates[j].Month
+		}
+		return dayOrder(dates[i].Day) < dayOrder(dates[j].Day)
+	})
+}
+
+func containsYearDate(dates []YearDate, target YearDate) bool {
+	for _, date := range dates {
+		if date == target {
+			return true
+		}
+	}
+	return false
+}
+
+// dayOrder ставит -1 и -2 после всех обычных дней месяца
+func dayOrder(day int) int {
+	if day < 0 {
+		return 33 + day
+	}
+	return day
+}
+
+// yearDatesExist проверяет, что хотя бы одна дата списка бывает на самом деле
+func yearDatesExist(dates []YearDate) bool {
+	for _, date := range dates {
+		if monthDaysExist([]int{date.Day}, []int{date.Month}) {
+			return true
+		}
+	}
+	return false
+}
+
+// yearDatesAlways проверяет, что все даты списка бывают в любой год
+func yearDatesAlways(dates []YearDate) bool {
+	for _, date := range dates {
+		// 2001 год невисокосный, в нём месяцы самые короткие
+		if date.Day > daysIn(2001, time.Month(date.Month)) {
+			return false
+		}
+	}
+	return true
+}
+
+// yearDatesGrid раскладывает даты на дни и месяцы, если даты - все дни во всех месяцах.
+// Только такие списки можно записать через BYMONTHDAY и BYMONTH.
+func yearDatesGrid(dates []YearDate) ([]int, []int, bool) {
+	var days, months []int
+	for _, date := range dates {
+		if !search(date.Day, days) {
+			days = append(days, date.Day)
+		}
+		if !search(date.Month, months) {
+			months = append(months, date.Month)
+		}
+	}
+	if len(days)*len(months) != len(dates) {
+		return nil, nil, false
+	}
+	sort.Ints(days)
+	sort.Ints(months)
+	return days, months, true
+}
+
+// nextYearDates ищет ближайшую дату из списка строго после max(now, date)
+func (r Rule) nextYearDates(now, date time.Time) time.Time {
+	after := now
+	if date.After(now) {
+		after = date
+	}
+
+	policy := r.monthEnd()
+	for year := after.Year(); year <= after.Year()+yearSearchYears; year++ {
+		var next time.Time
+		for _, d := range r.YearDates {
+			cur := calculateDate(year, d.Month, d.Day, after.Location(), policy)
+			if !cur.IsZero() && cur.After(after) && (next.IsZero() || cur.Before(next)) {
+				next = cur
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// nextISOWeek ищет ближайший день недели из Weekdays в ISO-неделях Weeks строго после max(now, date).
+// Неделя года считается по ISO 8601: первая неделя та, в которой 4 января.
+func (r Rule) nextISOWeek(now, date time.Time) time.Time {
+	after := now
+	if date.After(now) {
+		after = date
+	}
+
+	// Первые дни января могут относиться к последней неделе прошлого года
+	for year := after.Year() - 1; year <= after.Year()+yearSearchYears; year++ {
+		var next time.Time
+		monday := isoWeekStart(year, after.Location())
+		for _, week := range r.Weeks {
+			for _, weekday := range r.Weekdays {
+				cur := monday.AddDate(0, 0, (week-1)*7+weekday-1)
+				// 53-й недели в году может не быть
+				if _, w := cur.ISOWeek(); w != week {
+					continue
+				}
+				if cur.After(after) && (next.IsZero() || cur.Before(next)) {
+					next = cur
+				}
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// isoWeekStart возвращает понедельник первой ISO-недели года
+func isoWeekStart(year int, loc *time.Location) time.Time {
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	return jan4.AddDate(0, 0, 1-isoWeekday(jan4))
+}
+
+// yearBase возвращает запись правила y со списком дат или недель
+func (r Rule) yearBase() string {
+	if len(r.Weeks) > 0 {
+		return fmt.Sprintf("%s w%s %s", r.Kind, joinList(r.Weeks), joinList(r.Weekdays))
+	}
+	dates := make([]string, 0, len(r.YearDates))
+	for _, date := range r.YearDates {
+		dates = append(dates, fmt.Sprintf("%d.%d", date.Day, date.Month))
+	}
+	return fmt.Sprintf("%s %s", r.Kind, strings.Join(dates, ","))
+}
+
+// yearDescribeRu описание на русском: "каждый год 1 апреля и 1 октября"
+func (r Rule) yearDescribeRu() string {
+	if len(r.Weeks) > 0 {
+		weeks := make([]string, 0, len(r.Weeks))
+		for _, week := range r.Weeks {
+			weeks = append(weeks, strconv.Itoa(week)+"-й")
+		}
+		unit := "недели"
+		if len(r.Weeks) > 1 {
+			unit = "недель"
+		}
+		return "каждый год " + ruWeekdays(r.Weekdays) + " " + joinWords(weeks, "и") + " " + unit
+	}
+	dates := make([]string, 0, len(r.YearDates))
+	for _, date := range r.YearDates {
+		if date.Day < 0 {
+			dates = append(dates, ruMonthDays([]int{date.Day})+" "+ruMonthsGenitive[date.Month])
+			continue
+		}
+		dates = append(dates, fmt.Sprintf("%d %s", date.Day, ruMonthsGenitive[date.Month]))
+	}
+	return "каждый год " + joinWords(dates, "и")
+}
+
+// yearDescribeEn описание на английском: "every year on April 1 and October 1"
+func (r Rule) yearDescribeEn() string {
+	if len(r.Weeks) > 0 {
+		days := make([]string, 0, len(r.Weekdays))
+		for _, day := range r.Weekdays {
+			days = append(days, enWeekdays[day])
+		}
+		weeks := make([]string, 0, len(r.Weeks))
+		for _, week := range r.Weeks {
+			weeks = append(weeks, strconv.Itoa(week))
+		}
+		unit := "ISO week"
+		if len(r.Weeks) > 1 {
+			unit = "ISO weeks"
+		}
+		return "every year on " + joinWords(days, "and") + " of " + unit + " " + joinWords(weeks, "and")
+	}
+	dates := make([]string, 0, len(r.YearDates))
+	for _, date := range r.YearDates {
+		if date.Day < 0 {
+			dates = append(dates, "the "+enMonthDays([]int{date.Day})+" of "+enMonths[date.Month])
+			continue
+		}
+		dates = append(dates, fmt.Sprintf("%s %d", enMonths[date.Month], date.Day))
+	}
+	return "every year on " + joinWords(dates, "and")
+}
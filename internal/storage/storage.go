@@ -6,10 +6,19 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 )
 
 type Scheduler struct {
-	db *sql.DB
+	db dbConn
+}
+
+// Общее у *sql.DB и *sql.Tx, так одни и те же методы работают и внутри транзакции
+type dbConn interface {
+	Prepare(query string) (*sql.Stmt, error)
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 type Task struct {
@@ -18,6 +27,34 @@ type Task struct {
 	Title   string `json:"title"`
 	Comment string `json:"comment,omitempty"`
 	Repeat  string `json:"repeat,omitempty"`
+	Time    string `json:"time,omitempty"` // Необязательное время ЧЧ:ММ
+
+	// Несколько правил повторения списком, в БД хранятся в repeat через |
+	Repeats []string `json:"repeats,omitempty"`
+}
+
+// Состояние карточки интервального повторения
+type Review struct {
+	Step     int     // Сколько шагов пройдено
+	Ease     float64 // Множитель интервала
+	Interval int     // Последний интервал в днях
+}
+
+// Данные серии повторений, которые пишутся в БД вместе с задачей
+type Series struct {
+	Origin    string  // Начало серии 20060102, пустое - оставить как есть
+	Remaining int     // Сколько повторений осталось у серии с count
+	Counted   bool    // У серии есть count, иначе сохранённый остаток удаляется
+	Restart   bool    // Правило сменилось, карточка интервального повторения начинается заново
+	Review    *Review // Новое состояние карточки, nil - оставить как есть
+}
+
+// Пауза повторений, например отпуск. TaskID 0 - пауза для всех задач.
+type Pause struct {
+	ID     int64  `json:"id"`
+	TaskID int64  `json:"task_id,omitempty"`
+	From   string `json:"from"`
+	To     string `json:"to"`
 }
 
 // Не надумал более логичного решения проблемы, что нам иногда нужны все поля
@@ -29,6 +66,7 @@ type TaskNoEmpty struct {
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	Time    string `json:"time"`
 }
 
 // Функция открытия коннекта и создания БД, если её нет
@@ -67,19 +105,119 @@ func NewScheduler(DBPath string) (*Scheduler, *sql.DB) {
 		}
 	}
 
+	// Вспомогательные таблицы создаём всегда, чтобы они появились и в уже существующей БД
+	err = migrate(db)
+	if err != nil {
+		log.Fatal(err) // Без них часть функционала работать не будет, падаем
+	}
+
 	return &Scheduler{db: db}, db
+}
+
+// Колонки scheduler, которые появились после первой версии схемы
+var columns = []struct {
+	name       string
+	definition string
+}{
+	// Время задачи, пустая строка - задача на весь день
+	{"time", "VARCHAR(5) NOT NULL DEFAULT ''"},
+}
 
+// Таблицы, которые появились после первой версии схемы.
+// Дополнительные данные по задачам, которых может и не быть, лежат рядом с scheduler.
+var migrations = []string{
+	// Сколько повторений осталось у серии с ограничением count
+	`CREATE TABLE IF NOT EXISTS scheduler_series(
+		task_id INTEGER PRIMARY KEY,
+		remaining INTEGER NOT NULL
+	);`,
+	// Пропущенные повторения задач
+	`CREATE TABLE IF NOT EXISTS scheduler_skip(
+		task_id INTEGER NOT NULL,
+		date VARCHAR(8) NOT NULL,
+		PRIMARY KEY (task_id, date)
+	);`,
+	// Состояние карточек интервального повторения
+	`CREATE TABLE IF NOT EXISTS scheduler_review(
+		task_id INTEGER PRIMARY KEY,
+		step INTEGER NOT NULL,
+		ease REAL NOT NULL,
+		days INTEGER NOT NULL
+	);`,
+	// Дата, с которой началась серия повторений, задача с неё уже могла уехать
+	`CREATE TABLE IF NOT EXISTS scheduler_origin(
+		task_id INTEGER PRIMARY KEY,
+		date VARCHAR(8) NOT NULL
+	);`,
+	// Паузы повторений, task_id 0 - пауза для всех задач
+	`CREATE TABLE IF NOT EXISTS scheduler_pause(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL DEFAULT 0,
+		date_from VARCHAR(8) NOT NULL,
+		date_to VARCHAR(8) NOT NULL
+	);`,
+}
+
+// Функция для создания недостающих колонок и таблиц
+func migrate(db *sql.DB) error {
+	for _, column := range columns {
+		exists, err := hasColumn(db, "scheduler", column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE scheduler ADD COLUMN %s %s", column.name, column.definition))
+		if err != nil {
+			return fmt.Errorf("ошибка при обновлении схемы БД: %s", err)
+		}
+	}
+
+	for _, query := range migrations {
+		_, err := db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("ошибка при обновлении схемы БД: %s", err)
+		}
+	}
+	return nil
+
+}
+
+// Функция проверяет, есть ли у таблицы колонка
+func hasColumn(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("ошибка при чтении схемы БД: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("ошибка при чтении схемы БД: %s", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
 }
 
 // Функция инсерта в БД таски
 func (s *Scheduler) PostTask(task Task) (int, error) {
-	stmt, err := s.db.Prepare("INSERT INTO scheduler(date, title, comment, repeat) values(?,?,?,?)")
+	stmt, err := s.db.Prepare("INSERT INTO scheduler(date, title, comment, repeat, time) values(?,?,?,?,?)")
 	if err != nil {
 		return 0, err
 	}
 	defer stmt.Close()
 
-	res, err := stmt.Exec(task.Date, task.Title, task.Comment, task.Repeat)
+	res, err := stmt.Exec(task.Date, task.Title, task.Comment, task.Repeat, task.Time)
 	if err != nil {
 		return 0, fmt.Errorf("ошибка при попытке добавить таску в БД: %s", err)
 	}
@@ -92,11 +230,29 @@ func (s *Scheduler) PostTask(task Task) (int, error) {
 	return int(id), nil
 }
 
+// Функция добавляет задачу вместе с данными её серии одной транзакцией
+func (s *Scheduler) PostTaskSeries(task Task, series Series) (int, error) {
+	var id int
+	err := s.inTx(func(tx *Scheduler) error {
+		var err error
+		id, err = tx.PostTask(task)
+		if err != nil {
+			return err
+		}
+		return tx.saveSeries(strconv.Itoa(id), series)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
 // Функция для запроса у БД лимитированное кол-во тасок, ближайшее к текущей дате
 func (s Scheduler) GetTasks(limit int, today string) ([]TaskNoEmpty, error) {
-	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat " +
+	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat, time " +
 		"FROM scheduler WHERE date >= ? " +
-		"ORDER BY date ASC " +
+		"ORDER BY date ASC, time ASC " +
 		"LIMIT ?")
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
@@ -114,7 +270,7 @@ func (s Scheduler) GetTasks(limit int, today string) ([]TaskNoEmpty, error) {
 	tasks := []TaskNoEmpty{}
 	for rows.Next() {
 		var task TaskNoEmpty
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time); err != nil {
 			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
 		}
 		tasks = append(tasks, task)
@@ -128,8 +284,9 @@ func (s Scheduler) GetTasks(limit int, today string) ([]TaskNoEmpty, error) {
 
 // Отдельная функция для поиска по дате
 func (s Scheduler) GetTasksByDate(date string) ([]TaskNoEmpty, error) {
-	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat " +
-		"FROM scheduler WHERE date = ?")
+	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat, time " +
+		"FROM scheduler WHERE date = ? " +
+		"ORDER BY time ASC")
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
 	}
@@ -145,7 +302,7 @@ func (s Scheduler) GetTasksByDate(date string) ([]TaskNoEmpty, error) {
 	tasks := []TaskNoEmpty{}
 	for rows.Next() {
 		var task TaskNoEmpty
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time); err != nil {
 			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
 		}
 		tasks = append(tasks, task)
@@ -160,9 +317,9 @@ func (s Scheduler) GetTasksByDate(date string) ([]TaskNoEmpty, error) {
 
 // Отдельная функция для поиска по тексту, заголовок или коммент
 func (s Scheduler) GetTasksBySearch(limit int, today, search string) ([]TaskNoEmpty, error) {
-	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat " +
+	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat, time " +
 		"FROM scheduler WHERE date >= ? AND (title LIKE ? OR comment LIKE ?) " +
-		"ORDER BY date ASC " +
+		"ORDER BY date ASC, time ASC " +
 		"LIMIT ?")
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
@@ -181,7 +338,41 @@ func (s Scheduler) GetTasksBySearch(limit int, today, search string) ([]TaskNoEm
 	tasks := []TaskNoEmpty{}
 	for rows.Next() {
 		var task TaskNoEmpty
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
+		}
+		tasks = append(tasks, task)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при возврате строк: %s", err)
+	}
+
+	return tasks, nil
+}
+
+// Функция для календаря: разовые таски с датой в периоде и все повторяющиеся,
+// которые начинаются не позже конца периода
+func (s Scheduler) GetTasksForPeriod(from, to string) ([]TaskNoEmpty, error) {
+	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat, time " +
+		"FROM scheduler WHERE date <= ? AND (date >= ? OR repeat != '') " +
+		"ORDER BY date ASC, time ASC")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(to, from)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %s", err)
+	}
+	defer rows.Close()
+
+	// Пройдемся по всем полученным строкам и запишем их в слайс слайсов
+	tasks := []TaskNoEmpty{}
+	for rows.Next() {
+		var task TaskNoEmpty
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time); err != nil {
 			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
 		}
 		tasks = append(tasks, task)
@@ -198,7 +389,7 @@ func (s Scheduler) GetTasksBySearch(limit int, today, search string) ([]TaskNoEm
 func (s Scheduler) GetTaskByID(id string) (Task, error) {
 	task := Task{}
 	// Подготовим запрос к БД
-	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat " +
+	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat, time " +
 		"FROM scheduler WHERE id =?")
 	if err != nil {
 		return task, fmt.Errorf("ошибка при попытке найти задание в БД: %s", err)
@@ -208,10 +399,13 @@ func (s Scheduler) GetTaskByID(id string) (Task, error) {
 	// Так как id ключ с автоинкрементом, задача всегда будет одна
 	// Поэтому пользуемся QueryRow
 	query := stmt.QueryRow(id)
-	err = query.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	err = query.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time)
 	if errors.Is(err, sql.ErrNoRows) {
 		return task, fmt.Errorf("задача не найдена")
 	}
+	if err != nil {
+		return task, fmt.Errorf("ошибка при попытке найти задание в БД: %s", err)
+	}
 
 	return task, nil
 }
@@ -222,14 +416,15 @@ func (s *Scheduler) EditTask(task Task) error {
 		"date =?, " +
 		"title =?, " +
 		"comment =?, " +
-		"repeat =? " +
+		"repeat =?, " +
+		"time =? " +
 		"WHERE id =? ")
 	if err != nil {
 		return fmt.Errorf("ошибка при попытке изменить задачу: %s", err)
 	}
 	defer stmt.Close()
 
-	res, err := stmt.Exec(task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	res, err := stmt.Exec(task.Date, task.Title, task.Comment, task.Repeat, task.Time, task.ID)
 	if err != nil {
 		return fmt.Errorf("ошибка при попытке изменить задачу: %s", err)
 	}
@@ -247,7 +442,464 @@ func (s *Scheduler) EditTask(task Task) error {
 	return nil
 }
 
+// Функция изменяет задачу вместе с данными её серии одной транзакцией
+func (s *Scheduler) EditTaskSeries(task Task, series Series) error {
+	return s.inTx(func(tx *Scheduler) error {
+		err := tx.EditTask(task)
+		if err != nil {
+			return err
+		}
+		return tx.saveSeries(task.ID, series)
+	})
+}
+
+// Функция записывает данные серии задачи
+func (s *Scheduler) saveSeries(id string, series Series) error {
+	var err error
+	if series.Counted {
+		err = s.SetRemaining(id, series.Remaining)
+	} else {
+		err = s.DeleteRemaining(id)
+	}
+	if err == nil && series.Origin != "" {
+		err = s.SetOrigin(id, series.Origin)
+	}
+	if err == nil && series.Restart {
+		err = s.DeleteReview(id)
+	}
+	if err == nil && series.Review != nil {
+		err = s.SetReview(id, *series.Review)
+	}
+	return err
+}
+
+// Функция возвращает остаток повторений серии, второе значение false если записи нет
+func (s Scheduler) GetRemaining(id string) (int, bool, error) {
+	stmt, err := s.db.Prepare("SELECT remaining FROM scheduler_series WHERE task_id =?")
+	if err != nil {
+		return 0, false, fmt.Errorf("ошибка при попытке получить остаток повторений: %s", err)
+	}
+	defer stmt.Close()
+
+	var remaining int
+	err = stmt.QueryRow(id).Scan(&remaining)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("ошибка при попытке получить остаток повторений: %s", err)
+	}
+
+	return remaining, true, nil
+}
+
+// Функция сохраняет остаток повторений серии
+func (s *Scheduler) SetRemaining(id string, remaining int) error {
+	stmt, err := s.db.Prepare("INSERT INTO scheduler_series(task_id, remaining) VALUES(?,?) " +
+		"ON CONFLICT(task_id) DO UPDATE SET remaining = excluded.remaining")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке сохранить остаток повторений: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id, remaining)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке сохранить остаток повторений: %s", err)
+	}
+
+	return nil
+}
+
+// Функция удаляет остаток повторений, если у задачи больше нет ограничения count
+func (s *Scheduler) DeleteRemaining(id string) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduler_series WHERE task_id =?")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить остаток повторений: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить остаток повторений: %s", err)
+	}
+
+	return nil
+}
+
+// Функция возвращает дату начала серии задачи, второе значение false если записи нет
+func (s Scheduler) GetOrigin(id string) (string, bool, error) {
+	stmt, err := s.db.Prepare("SELECT date FROM scheduler_origin WHERE task_id =?")
+	if err != nil {
+		return "", false, fmt.Errorf("ошибка при попытке получить начало серии: %s", err)
+	}
+	defer stmt.Close()
+
+	var date string
+	err = stmt.QueryRow(id).Scan(&date)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("ошибка при попытке получить начало серии: %s", err)
+	}
+
+	return date, true, nil
+}
+
+// Функция сохраняет дату начала серии задачи
+func (s *Scheduler) SetOrigin(id, date string) error {
+	stmt, err := s.db.Prepare("INSERT INTO scheduler_origin(task_id, date) VALUES(?,?) " +
+		"ON CONFLICT(task_id) DO UPDATE SET date = excluded.date")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке сохранить начало серии: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id, date)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке сохранить начало серии: %s", err)
+	}
+
+	return nil
+}
+
+// Функция удаляет дату начала серии задачи
+func (s *Scheduler) DeleteOrigin(id string) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduler_origin WHERE task_id =?")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить начало серии: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить начало серии: %s", err)
+	}
+
+	return nil
+}
+
+// Функция добавляет дату в пропуски задачи, повторно одну дату не пишем
+func (s *Scheduler) AddSkip(id, date string) error {
+	stmt, err := s.db.Prepare("INSERT OR IGNORE INTO scheduler_skip(task_id, date) VALUES(?,?)")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке пропустить повторение: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id, date)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке пропустить повторение: %s", err)
+	}
+
+	return nil
+}
+
+// Функция возвращает все пропущенные даты задачи
+func (s Scheduler) GetSkips(id string) ([]string, error) {
+	stmt, err := s.db.Prepare("SELECT date FROM scheduler_skip WHERE task_id =? ORDER BY date ASC")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %s", err)
+	}
+	defer rows.Close()
+
+	dates := []string{}
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
+		}
+		dates = append(dates, date)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при возврате строк: %s", err)
+	}
+
+	return dates, nil
+}
+
+// Функция удаляет все пропуски задачи
+func (s *Scheduler) DeleteSkips(id string) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduler_skip WHERE task_id =?")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить пропуски: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить пропуски: %s", err)
+	}
+
+	return nil
+}
+
+// Функция возвращает состояние карточки задачи, второе значение false если записи нет
+func (s Scheduler) GetReview(id string) (Review, bool, error) {
+	stmt, err := s.db.Prepare("SELECT step, ease, days FROM scheduler_review WHERE task_id =?")
+	if err != nil {
+		return Review{}, false, fmt.Errorf("ошибка при попытке получить состояние карточки: %s", err)
+	}
+	defer stmt.Close()
+
+	var review Review
+	err = stmt.QueryRow(id).Scan(&review.Step, &review.Ease, &review.Interval)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Review{}, false, nil
+	}
+	if err != nil {
+		return Review{}, false, fmt.Errorf("ошибка при попытке получить состояние карточки: %s", err)
+	}
+
+	return review, true, nil
+}
+
+// Функция сохраняет состояние карточки задачи
+func (s *Scheduler) SetReview(id string, review Review) error {
+	stmt, err := s.db.Prepare("INSERT INTO scheduler_review(task_id, step, ease, days) VALUES(?,?,?,?) " +
+		"ON CONFLICT(task_id) DO UPDATE SET step = excluded.step, ease = excluded.ease, days = excluded.days")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке сохранить состояние карточки: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id, review.Step, review.Ease, review.Interval)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке сохранить состояние карточки: %s", err)
+	}
+
+	return nil
+}
+
+// Функция удаляет состояние карточки, со следующего выполнения она начнётся заново
+func (s *Scheduler) DeleteReview(id string) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduler_review WHERE task_id =?")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить состояние карточки: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить состояние карточки: %s", err)
+	}
+
+	return nil
+}
+
+// Функция добавляет паузу повторений
+func (s *Scheduler) AddPause(pause Pause) (int64, error) {
+	stmt, err := s.db.Prepare("INSERT INTO scheduler_pause(task_id, date_from, date_to) VALUES(?,?,?)")
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при попытке добавить паузу: %s", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(pause.TaskID, pause.From, pause.To)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при попытке добавить паузу: %s", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при попытке добавить паузу: %s", err)
+	}
+
+	return id, nil
+}
+
+// Функция ищет паузу по ID
+func (s Scheduler) GetPauseByID(id string) (Pause, error) {
+	stmt, err := s.db.Prepare("SELECT id, task_id, date_from, date_to FROM scheduler_pause WHERE id =?")
+	if err != nil {
+		return Pause{}, fmt.Errorf("ошибка при попытке найти паузу: %s", err)
+	}
+	defer stmt.Close()
+
+	var pause Pause
+	err = stmt.QueryRow(id).Scan(&pause.ID, &pause.TaskID, &pause.From, &pause.To)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Pause{}, fmt.Errorf("пауза не найдена")
+	}
+	if err != nil {
+		return Pause{}, fmt.Errorf("ошибка при попытке найти паузу: %s", err)
+	}
+
+	return pause, nil
+}
+
+// Функция возвращает паузы, которые действуют на задачу: общие и её собственные.
+// Пустой id - только общие паузы.
+func (s Scheduler) GetPauses(id string) ([]Pause, error) {
+	stmt, err := s.db.Prepare("SELECT id, task_id, date_from, date_to FROM scheduler_pause " +
+		"WHERE task_id = 0 OR task_id =? " +
+		"ORDER BY date_from ASC")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %s", err)
+	}
+	defer rows.Close()
+
+	return scanPauses(rows)
+}
+
+// Функция возвращает все паузы, общие и у отдельных задач
+func (s Scheduler) GetAllPauses() ([]Pause, error) {
+	stmt, err := s.db.Prepare("SELECT id, task_id, date_from, date_to FROM scheduler_pause " +
+		"ORDER BY date_from ASC")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %s", err)
+	}
+	defer rows.Close()
+
+	return scanPauses(rows)
+}
+
+// Функция удаляет паузу по ID
+func (s *Scheduler) DeletePause(id string) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduler_pause WHERE id =?")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить паузу: %s", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить паузу: %s", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить паузу: %s", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Функция удаляет собственные паузы задачи, общие остаются
+func (s *Scheduler) DeleteTaskPauses(id string) error {
+	stmt, err := s.db.Prepare("DELETE FROM scheduler_pause WHERE task_id =? AND task_id != 0")
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить паузы задачи: %s", err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке удалить паузы задачи: %s", err)
+	}
+
+	return nil
+}
+
+// Функция для перечня задач, чьи даты попали в период from - to включительно.
+// id задачи ограничивает выборку одной задачей, пустой - все задачи.
+func (s Scheduler) GetTasksInRange(from, to, id string) ([]Task, error) {
+	stmt, err := s.db.Prepare("SELECT id, date, title, comment, repeat, time " +
+		"FROM scheduler WHERE date >= ? AND date <= ? AND (? = '' OR id = ?) " +
+		"ORDER BY date ASC, time ASC")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при подготовке запроса: %s", err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(from, to, id, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при выполнении запроса: %s", err)
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		var task Task
+		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
+		}
+		tasks = append(tasks, task)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при возврате строк: %s", err)
+	}
+
+	return tasks, nil
+}
+
+// Функция читает паузы из строк запроса
+func scanPauses(rows *sql.Rows) ([]Pause, error) {
+	pauses := []Pause{}
+	for rows.Next() {
+		var pause Pause
+		if err := rows.Scan(&pause.ID, &pause.TaskID, &pause.From, &pause.To); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении строки: %s", err)
+		}
+		pauses = append(pauses, pause)
+	}
+	err := rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при возврате строк: %s", err)
+	}
+
+	return pauses, nil
+}
+
+// Функция выполняет fn в одной транзакции: записи через tx применятся либо все, либо ни одна.
+// Если s уже внутри транзакции, fn выполняется в ней же.
+func (s *Scheduler) inTx(fn func(tx *Scheduler) error) error {
+	db, ok := s.db.(*sql.DB)
+	if !ok {
+		return fn(s)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке начать транзакцию: %s", err)
+	}
+
+	err = fn(&Scheduler{db: tx})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("ошибка при попытке завершить транзакцию: %s", err)
+	}
+
+	return nil
+}
+
+// Функция удаляет задачу вместе с её серией одной транзакцией
 func (s *Scheduler) DeleteTaskByID(id string) error {
+	return s.inTx(func(tx *Scheduler) error {
+		return tx.deleteTask(id)
+	})
+}
+
+func (s *Scheduler) deleteTask(id string) error {
 	// Подготовим запрос к БД
 	stmt, err := s.db.Prepare("DELETE FROM scheduler WHERE id=?")
 	if err != nil {
@@ -270,6 +922,22 @@ func (s *Scheduler) DeleteTaskByID(id string) error {
 		return sql.ErrNoRows
 	}
 
-	return nil
-
+	// Вместе с задачей чистим и её серию с началом, пропусками, паузами и карточкой
+	err = s.DeleteRemaining(id)
+	if err != nil {
+		return err
+	}
+	err = s.DeleteTaskPauses(id)
+	if err != nil {
+		return err
+	}
+	err = s.DeleteOrigin(id)
+	if err != nil {
+		return err
+	}
+	err = s.DeleteReview(id)
+	if err != nil {
+		return err
+	}
+	return s.DeleteSkips(id)
 }
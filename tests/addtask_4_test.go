@@ -91,6 +91,11 @@ func TestAddTask(t *testing.T) {
 		{"28.01.2024", "Заголовок", "", ""},
 		{"20240112", "Заголовок", "", "w"},
 		{"20240212", "Заголовок", "", "ooops"},
+		{"20240212", "Заголовок", "", "m 30 2"},
+		{"20990101", "Заголовок", "", "m 31 4,6,9,11"},
+		{"20990101", "Заголовок", "", "FREQ=MONTHLY;BYDAY=MO;BYSETPOS=6"},
+		// Сам по себе правильный RRULE, но от 2099 года каждые 4 года високосных лет не будет
+		{"20990101", "Заголовок", "", "FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29"},
 	}
 	for _, v := range tbl {
 		m, err := postJSON("api/task", map[string]any{
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type calendarTask struct {
+	ID        string `json:"id"`
+	Date      string `json:"date"`
+	Time      string `json:"time"`
+	Projected bool   `json:"projected"`
+}
+
+type calendarDay struct {
+	Date  string         `json:"date"`
+	Tasks []calendarTask `json:"tasks"`
+}
+
+type calendarResp struct {
+	Days      []calendarDay `json:"days"`
+	Truncated bool          `json:"truncated"`
+	Err       string        `json:"error"`
+}
+
+func getCalendar(t *testing.T, query string) calendarResp {
+	body, err := requestJSON("api/calendar?"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp calendarResp
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+// Даты, на которые в календаре попала таска id
+func calendarDates(t *testing.T, resp calendarResp, id string) []string {
+	var dates []string
+	for _, day := range resp.Days {
+		for _, task := range day.Tasks {
+			if task.ID == id {
+				assert.Equal(t, day.Date, task.Date)
+				dates = append(dates, task.Date)
+			}
+		}
+	}
+	return dates
+}
+
+func TestCalendar(t *testing.T) {
+	weekly := addTask(t, task{
+		date:   "20300107",
+		title:  "Планёрка",
+		repeat: "w 1",
+	})
+	once := addTask(t, task{
+		date:  "20300115",
+		title: "Сдать отчёт",
+	})
+	later := addTask(t, task{
+		date:  "20300301",
+		title: "Вне периода",
+	})
+
+	resp := getCalendar(t, "from=20300101&to=20300131")
+	assert.Empty(t, resp.Err)
+	assert.Equal(t, []string{"20300107", "20300114", "20300121", "20300128"}, calendarDates(t, resp, weekly))
+	assert.Equal(t, []string{"20300115"}, calendarDates(t, resp, once))
+	assert.Empty(t, calendarDates(t, resp, later))
+	assert.False(t, resp.Truncated)
+
+	// Дни идут по порядку, первая дата еженедельной таски - её дата в БД
+	for i := 1; i < len(resp.Days); i++ {
+		assert.Less(t, resp.Days[i-1].Date, resp.Days[i].Date)
+	}
+	for _, day := range resp.Days {
+		for _, task := range day.Tasks {
+			if task.ID == weekly {
+				assert.Equal(t, task.Date != "20300107", task.Projected)
+			}
+		}
+	}
+
+	// Период после даты в БД: повторения считаются от неё
+	resp = getCalendar(t, "from=20300210&to=20300216")
+	assert.Equal(t, []string{"20300211"}, calendarDates(t, resp, weekly))
+
+	// Неверный период
+	for _, query := range []string{"from=2030", "from=20300201&to=20300101", "from=20300101&to=20320101"} {
+		resp = getCalendar(t, query)
+		assert.NotEmpty(t, resp.Err, query)
+	}
+
+	for _, id := range []string{weekly, once, later} {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCalendarTruncated(t *testing.T) {
+	hourly := addTask(t, task{
+		date:   "20310101",
+		title:  "Проверить датчик",
+		repeat: "h 1",
+	})
+
+	// За неделю 168 повторений, все на месте
+	resp := getCalendar(t, "from=20310101&to=20310107")
+	assert.Empty(t, resp.Err)
+	assert.Len(t, calendarDates(t, resp, hourly), 7*24)
+	assert.False(t, resp.Truncated)
+
+	// За год их 8760, календарь показывает первые 1000 и сообщает, что обрезал
+	resp = getCalendar(t, "from=20310101&to=20311231")
+	assert.Empty(t, resp.Err)
+	dates := calendarDates(t, resp, hourly)
+	assert.Len(t, dates, 1000)
+	assert.True(t, resp.Truncated)
+	if len(dates) > 0 {
+		assert.Equal(t, "20310101", dates[0])
+		assert.Equal(t, "20310211", dates[len(dates)-1])
+	}
+
+	_, err := postJSON("api/task?id="+hourly, nil, http.MethodDelete)
+	assert.NoError(t, err)
+}
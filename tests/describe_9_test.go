@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type describe struct {
+	repeat string
+	lang   string
+	want   string
+}
+
+type describeResp struct {
+	Description string   `json:"description"`
+	Next        string   `json:"next"`
+	Steps       []string `json:"steps"`
+	Err         string   `json:"error"`
+}
+
+func getDescribe(t *testing.T, query string) describeResp {
+	body, err := getBody("api/repeat/describe?" + query)
+	assert.NoError(t, err)
+	var resp describeResp
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestDescribe(t *testing.T) {
+	tbl := []describe{
+		{"m 1,-1 2,8", "ru", "1-го и последнего числа февраля и августа"},
+		{"m 1,-1 2,8", "en", "on the 1st and last day of February and August"},
+		{"d 1", "", "каждый день"},
+		{"d 3", "ru", "каждые 3 дня"},
+		{"d 21", "ru", "каждый 21 день"},
+		{"d 7", "en", "every 7 days"},
+		{"w 1,3", "ru", "по понедельникам и средам"},
+		{"w 1,3", "en", "every Monday and Wednesday"},
+		{"w 1 /2", "ru", "каждые 2 недели, по понедельникам"},
+		{"w 1 /2", "en", "every 2 weeks on Monday"},
+		{"mw 2:2 3", "ru", "во второй вторник марта"},
+		{"mw 1:1,-1:5", "en", "on the first Monday and last Friday of every month"},
+		{"y", "ru", "каждый год в день даты задачи"},
+		{"y 1.4,1.10", "ru", "каждый год 1 апреля и 1 октября"},
+		{"y w1,27 1", "en", "every year on Monday of ISO weeks 1 and 27"},
+		{"d 7 count 3", "ru", "каждые 7 дней, всего 3 раза"},
+		{"d 3 after", "ru", "каждые 3 дня, считая от последнего выполнения"},
+		{"d 1 at 09:00 until 20251231", "en", "every day at 09:00, until 2025-12-31"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "ru", "каждые 2 недели, по понедельникам и пятницам"},
+	}
+	for _, v := range tbl {
+		resp := getDescribe(t, fmt.Sprintf("repeat=%s&lang=%s", url.QueryEscape(v.repeat), v.lang))
+		assert.Empty(t, resp.Err, v.repeat)
+		assert.Equal(t, v.want, resp.Description, `{%q, %q}`, v.repeat, v.lang)
+	}
+
+	// Некорректное правило и неизвестный язык это ошибка
+	for _, query := range []string{"repeat=k+34", "repeat=d+1&lang=fr"} {
+		resp := getDescribe(t, query)
+		assert.NotEmpty(t, resp.Err, query)
+	}
+}
+
+func TestDescribeExplain(t *testing.T) {
+	resp := getDescribe(t, "repeat=d+7&now=20240126&date=20240113")
+	assert.Empty(t, resp.Err)
+	assert.Equal(t, "20240127", resp.Next)
+	assert.Contains(t, resp.Steps, "Прибавляем интервал к дате задачи, пока не окажемся позже 20240126: 20240113 → 20240120 → 20240127.")
+	assert.Equal(t, "Следующая дата: 20240127.", resp.Steps[len(resp.Steps)-1])
+
+	// Объяснение сходится с /api/nextdate
+	for _, v := range []nextDate{
+		{"20240125", "w 1,3", "20240129"},
+		{"20240127", "m -1", "20240131"},
+		{"20240113", "d 7 count 2", ""},
+	} {
+		resp := getDescribe(t, fmt.Sprintf("repeat=%s&now=20240126&date=%s&lang=en", url.QueryEscape(v.repeat), v.date))
+		assert.Empty(t, resp.Err, v.repeat)
+		assert.Equal(t, v.want, resp.Next, v.repeat)
+		assert.NotEmpty(t, resp.Steps, v.repeat)
+	}
+}
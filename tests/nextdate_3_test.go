@@ -79,3 +79,333 @@ func TestNextDate(t *testing.T) {
 	}
 	check()
 }
+
+// checkNextDate прогоняет таблицу через /api/nextdate с now=20240126
+func checkNextDate(t *testing.T, tbl []nextDate) {
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestNextDateRRule(t *testing.T) {
+	checkNextDate(t, []nextDate{
+		{"20240101", "FREQ=DAILY;INTERVAL=7", "20240129"},
+		{"20240101", "FREQ=WEEKLY;BYDAY=MO,WE;INTERVAL=2", "20240129"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=13;BYDAY=FR", "20240913"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=3;BYDAY=2TU", "20240312"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", "20240229"},
+		{"20240101", "FREQ=DAILY;UNTIL=20240127", "20240127"},
+		{"20240101", "FREQ=DAILY;COUNT=5", ""},
+		{"20240101", "FREQ=HOURLY", ""},
+		{"20240101", "FREQ=DAILY;COUNT=5;UNTIL=20240127", ""},
+		{"20240101", "FREQ=WEEKLY;BYDAY=2MO", ""},
+		// Пятый понедельник бывает не каждый месяц, шестого не бывает никогда
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO;BYSETPOS=5", "20240129"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO;BYSETPOS=6", ""},
+		{"20240101", "FREQ=MONTHLY;BYDAY=6MO", ""},
+		{"20240101", "FREQ=WEEKLY;BYDAY=MO,TU;BYSETPOS=3", ""},
+		{"20240101", "FREQ=DAILY;BYSETPOS=-2", ""},
+		{"20240101", "FREQ=DAILY;INTERVAL=9223372036854775807", ""},
+		{"20240101", "FREQ=DAILY;INTERVAL=100000", ""},
+	})
+}
+
+func TestNextDateMonthWeekday(t *testing.T) {
+	checkNextDate(t, []nextDate{
+		{"20240101", "mw 1:1", "20240205"},
+		{"20240101", "mw 2:2", "20240213"},
+		{"20240101", "mw -1:5", "20240223"},
+		{"20240101", "mw -1:5 3,9", "20240329"},
+		{"20240301", "mw 1:1,-1:5", "20240304"},
+		{"20240101", "mw 5:1 2", "20440229"},
+		{"20240101", "mw 4:4", "20240222"},
+		{"20240101", "mw 4:4 1", "20250123"},
+		{"20240101", "mw 0:1", ""},
+		{"20240101", "mw 6:1", ""},
+		{"20240101", "mw 1:8", ""},
+		{"20240101", "mw 1", ""},
+		{"20240101", "mw", ""},
+		{"20240101", "mw 1:1 13", ""},
+	})
+}
+
+func TestNextDateSeries(t *testing.T) {
+	checkNextDate(t, []nextDate{
+		{"20240113", "d 7 until 20240131", "20240127"},
+		{"20240113", "d 7 until 20240126", ""},
+		{"20240113", "d 7 count 3", "20240127"},
+		{"20240113", "d 7 count 2", ""},
+		{"20240101", "w 1 count 5", "20240129"},
+		{"20240101", "w 1 count 4", ""},
+		{"20240101", "FREQ=WEEKLY;BYDAY=MO;COUNT=5", "20240129"},
+		{"20240101", "FREQ=WEEKLY;BYDAY=MO;UNTIL=20240128", ""},
+		{"20240113", "d 7 count 0", ""},
+		{"20240113", "d 7 count", ""},
+		{"20240113", "d 7 until 2024", ""},
+		{"20240113", "d 7 until 20240131 count 3", ""},
+	})
+}
+
+// Файл праздников серверу в тестах не передаётся, рабочими считаются будни
+func TestNextDateWorkdays(t *testing.T) {
+	checkNextDate(t, []nextDate{
+		{"20240101", "m 3 roll next", "20240205"},
+		{"20240101", "m 3 roll prev", "20240202"},
+		{"20240101", "m 4 roll nearest", "20240205"},
+		{"20240101", "m 3 roll nearest", "20240202"},
+		{"20240101", "m 27 roll next", "20240129"},
+		{"20240101", "m 27 roll prev", "20240227"},
+		{"20240122", "d 5 workdays", "20240129"},
+		{"20240126", "d 1 workdays", "20240129"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=3 roll next", "20240205"},
+		{"20240101", "m 3 roll sideways", ""},
+		{"20240101", "w 1 workdays", ""},
+		{"20240101", "d 5 workdays 3", ""},
+	})
+}
+
+func TestNextDateTime(t *testing.T) {
+	checkNextDate(t, []nextDate{
+		{"20240120", "h 5", "20240126"},
+		{"20240125", "h 24", "20240127"},
+		{"20240125", "d 1 at 09:30", "20240126"},
+		{"20240101", "m 26 at 07:00", "20240126"},
+		{"20240101", "w 5 at 18:00", "20240126"},
+		{"20240120", "h 0", ""},
+		{"20240120", "h 25", ""},
+		{"20240120", "h 4 at 10:00", ""},
+		{"20240120", "d 1 at 9:3", ""},
+	})
+}
+
+func TestNextDateImpossible(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "m 30 2", ""},
+		{"20240126", "m 31 4,6,9,11", ""},
+		{"20240126", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", ""},
+		// Ближайшее 29 февраля дальше, чем через два года
+		{"20240301", "m 29 2", "20280229"},
+		{"20240126", "m 31,-1 2", "20240229"},
+	}
+	checkNextDate(t, tbl)
+
+	// Для невозможного правила вместо пустой даты приходит ошибка
+	body, err := getBody("api/nextdate?now=20240126&date=20240126&repeat=" + url.QueryEscape("m 30 2"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "error")
+}
+
+func TestNextDateInterval(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "y 1", "20250126"},
+		{"20240126", "y 2", "20260126"},
+		{"20200315", "y 2", "20240315"},
+		{"20210315", "y 3", "20240315"},
+		{"20240301", "y 5", "20290301"},
+		{"20240126", "y 0", ""},
+		{"20240126", "y 101", ""},
+		{"20240126", "y 2 3", ""},
+		{"20231015", "m 15 /3", "20240415"},
+		{"20231115", "m 15 /3", "20240215"},
+		{"20240201", "m 1,-1 /2", "20240229"},
+		{"20240131", "m 31 /2", "20240331"},
+		{"20240120", "m 31 /1", "20240131"},
+		{"20220610", "m 10 /12", "20240610"},
+		{"20240126", "m 15 /0", ""},
+		{"20240126", "m 15 /3 5", ""},
+		{"20240126", "m /3", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateWeekInterval(t *testing.T) {
+	tbl := []nextDate{
+		// Дата задачи в будущем, отсчёт идёт от её дня недели
+		{"20240201", "w 1", "20240205"},
+		{"20240130", "w 3,5", "20240131"},
+		{"20240101", "w 1 /2", "20240129"},
+		{"20240108", "w 1 /2", "20240205"},
+		{"20240108", "w 1,5 /2", "20240205"},
+		{"20240201", "w 1 /3", "20240219"},
+		{"20240126", "w 1 /1", "20240129"},
+		{"20240126", "w 1 /0", ""},
+		{"20240126", "w 1 /53", ""},
+		{"20240126", "w 1 2", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateAfterDone(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "d 3 after", "20240129"},
+		{"20240201", "d 3 after", "20240129"},
+		{"20240126", "d 1 after", "20240127"},
+		{"20240201", "w 1 after", "20240129"},
+		{"20240301", "m 10 after", "20240210"},
+		{"20240126", "d 3 after after", ""},
+		{"20240126", "d 3 after 5", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateMonthEnd(t *testing.T) {
+	tbl := []nextDate{
+		// y: по умолчанию 29 февраля переполняется в 1 марта
+		{"20240229", "y", "20250301"},
+		{"20240229", "y monthend overflow", "20250301"},
+		{"20240229", "y monthend clamp", "20250228"},
+		{"20240229", "y monthend skip", "20280229"},
+		{"20230228", "y monthend clamp", "20240228"},
+		{"20200229", "y 3 monthend clamp", "20260228"},
+		{"20200229", "y 3 monthend skip", "20320229"},
+		// m: по умолчанию месяцы без такого дня пропускаются
+		{"20240401", "m 31", "20240531"},
+		{"20240401", "m 31 monthend skip", "20240531"},
+		{"20240401", "m 31 monthend clamp", "20240430"},
+		{"20240401", "m 31 monthend overflow", "20240501"},
+		{"20240126", "m 30 2", ""},
+		{"20240126", "m 30 2 monthend clamp", "20240229"},
+		{"20240126", "m 30 2 monthend overflow", "20240301"},
+		{"20240331", "m 31 /3 monthend clamp", "20240630"},
+		{"20240401", "m 31 /2 monthend clamp", "20240430"},
+		// d: политика касается повторений на 29 февраля
+		{"20240222", "d 7", "20240229"},
+		{"20240222", "d 7 monthend overflow", "20240229"},
+		{"20240222", "d 7 monthend clamp", "20240228"},
+		{"20240222", "d 7 monthend skip", "20240307"},
+		{"20240126", "h 2 monthend clamp", ""},
+		{"20240126", "w 1 monthend clamp", ""},
+		{"20240126", "d 1 monthend sideways", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateCron(t *testing.T) {
+	// now = 20240126, пятница
+	tbl := []nextDate{
+		{"20240126", "0 9 * * 1-5", "20240126"},
+		{"20240126", "0 9 * * MON", "20240129"},
+		{"20240126", "cron 0 9 * * mon-fri", "20240126"},
+		{"20240126", "30 8 1 * *", "20240201"},
+		{"20240126", "0 12 * JUN *", "20240601"},
+		{"20240126", "@monthly", "20240201"},
+		{"20240205", "0 9 * * 1", "20240205"},
+		// День месяца и день недели вместе работают как "или": 13-е или пятница
+		{"20240126", "0 0 13 * 5", "20240202"},
+		// Если одно из полей начинается с *, день должен подойти под оба: нечётное число и понедельник
+		{"20240126", "0 0 */2 * 1", "20240129"},
+		{"20240130", "0 0 */2 * 1", "20240205"},
+		{"20240126", "0 0 1-31 * 1", "20240127"},
+		{"20240126", "0 0 29 2 *", "20240229"},
+		{"20240126", "0 0 30 2 *", ""},
+		{"20240126", "0 9 * *", ""},
+		{"20240126", "61 * * * *", ""},
+		{"20240126", "0 9 * * 1-5 at 10:00", ""},
+		{"20240126", "0 9 5-1 * *", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateSpaced(t *testing.T) {
+	tbl := []nextDate{
+		// Без оценки новая карточка повторяется через первый шаг от сегодня
+		{"20240126", "sr", "20240127"},
+		{"20240301", "sr", "20240127"},
+		{"20240126", "sr 2,5", "20240128"},
+		{"20240126", "sr 0", ""},
+		{"20240126", "sr 1 2", ""},
+		{"20240126", "sr monthend clamp", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateUnion(t *testing.T) {
+	// now = 20240126, пятница
+	tbl := []nextDate{
+		{"20240126", "w 1 | m -1", "20240129"},
+		{"20240126", "m -1|w 1", "20240129"},
+		{"20240126", "m 1 | w 7", "20240128"},
+		{"20240126", "d 30 | y", "20240225"},
+		{"20240301", "w 3 | m 31", "20240306"},
+		// Закончившееся правило просто не участвует
+		{"20240126", "w 1 until 20240101 | m -1", "20240131"},
+		{"20240126", "w 1 |", ""},
+		{"20240126", "| m 1", ""},
+		{"20240126", "w 1 | sr", ""},
+		{"20240126", "w 1 count 3 | m 1", ""},
+		{"20240126", "w 1 | k 1", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateAltCalendar(t *testing.T) {
+	// now = 20240126
+	tbl := []nextDate{
+		// Китайский новый год и праздник середины осени
+		{"20240126", "chinese 1 1", "20240210"},
+		{"20240126", "chinese 15 8", "20240917"},
+		{"20240301", "chinese 1 1", "20250129"},
+		// Песах, Рош ха-Шана и Пурим, в 5784 году два адара
+		{"20240126", "hebrew 15 nisan", "20240423"},
+		{"20240126", "hebrew 1 tishri", "20241003"},
+		{"20240126", "hebrew 14 adar", "20240324"},
+		{"20240126", "hebrew 14 adar1", "20240223"},
+		// Рамадан и ураза-байрам по табличному календарю
+		{"20240126", "hijri 1 9", "20240311"},
+		{"20240126", "hijri 1 10", "20240410"},
+		// 30-го числа в 1-м месяце 2024 года нет
+		{"20240126", "chinese 30 1 monthend clamp", "20240309"},
+		{"20240126", "hebrew 30 iyar monthend skip", ""},
+		{"20240126", "hijri 30 2 monthend skip", ""},
+		{"20240126", "chinese 1 13", ""},
+		{"20240126", "hebrew 1 foo", ""},
+		{"20240126", "hijri 1", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateYearLists(t *testing.T) {
+	// now = 20240126
+	tbl := []nextDate{
+		{"20240126", "y 1.4,1.10", "20240401"},
+		{"20240501", "y 1.4,1.10", "20241001"},
+		{"20241001", "y 1.10,1.4", "20250401"},
+		{"20240126", "y -1.2", "20240229"},
+		{"20240301", "y 29.2 monthend skip", "20280229"},
+		{"20240301", "y 29.2 monthend clamp", "20250228"},
+		// Понедельник 1-й и 27-й ISO-недели, 53-я неделя бывает не каждый год
+		{"20240126", "y w1,27 1", "20240701"},
+		{"20240702", "y w1,27 1", "20241230"},
+		{"20240126", "y w53 7", "20270103"},
+		{"20240126", "y 30.2 monthend skip", ""},
+		{"20240126", "y 1.13", ""},
+		{"20240126", "y 0.1", ""},
+		{"20240126", "y 1.4 2", ""},
+		{"20240126", "y w1", ""},
+		{"20240126", "y w54 1", ""},
+	}
+	checkNextDate(t, tbl)
+}
+
+func TestNextDateScript(t *testing.T) {
+	// Скрипты сервер берёт из папки rules
+	tbl := []nextDate{
+		{"20240126", "x payday", "20240223"},
+		{"20240223", "x payday", "20240325"},
+		{"20240126", "x missing", ""},
+		{"20240126", "x", ""},
+	}
+	checkNextDate(t, tbl)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type occurrences struct {
+	date   string
+	repeat string
+	n      int
+	want   []string
+}
+
+func TestOccurrences(t *testing.T) {
+	tbl := []occurrences{
+		{"20240113", "d 7", 3, []string{"20240127", "20240203", "20240210"}},
+		{"20240101", "y", 2, []string{"20250101", "20260101"}},
+		{"20240125", "w 1,3", 4, []string{"20240129", "20240131", "20240205", "20240207"}},
+		{"20240101", "w 1 /2", 3, []string{"20240129", "20240212", "20240226"}},
+		{"20240127", "m -1", 3, []string{"20240131", "20240229", "20240331"}},
+		{"20240126", "sr", 4, []string{"20240127", "20240130", "20240206", "20240220"}},
+		{"20240126", "k 34", 3, nil},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/occurrences?now=20240126&date=%s&repeat=%s&n=%d",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat), v.n)
+		body, err := requestJSON(urlPath, nil, http.MethodGet)
+		assert.NoError(t, err)
+
+		var dates []string
+		err = json.Unmarshal(body, &dates)
+		if v.want == nil {
+			assert.Error(t, err, `{%q, %q}`, v.date, v.repeat)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, dates, `{%q, %q}`, v.date, v.repeat)
+	}
+
+	// Количество дат ограничено сверху
+	body, err := requestJSON("api/occurrences?now=20240126&date=20240126&repeat=d+1&n=100000", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var dates []string
+	assert.NoError(t, json.Unmarshal(body, &dates))
+	assert.Len(t, dates, 100)
+}
+
+func TestOccurrencesTimeZone(t *testing.T) {
+	// Между этими поясами 25 часов, "сегодня" у них всегда разное
+	first := func(tz string) string {
+		body, err := requestJSON("api/occurrences?repeat=d+1&n=1&tz="+url.QueryEscape(tz), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var dates []string
+		assert.NoError(t, json.Unmarshal(body, &dates))
+		if !assert.Len(t, dates, 1) {
+			return ""
+		}
+		return dates[0]
+	}
+	assert.Greater(t, first("Pacific/Kiritimati"), first("Pacific/Pago_Pago"))
+
+	// Неизвестный пояс это ошибка
+	body, err := requestJSON("api/occurrences?repeat=d+1&tz=Mars%2FOlympus", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp map[string]any
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.NotEmpty(t, resp["error"])
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type shiftedTask struct {
+	ID      string `json:"id"`
+	Date    string `json:"date"`
+	NewDate string `json:"new_date"`
+	Deleted bool   `json:"deleted"`
+	Skipped string `json:"skipped"`
+}
+
+type shiftResp struct {
+	DryRun bool          `json:"dry_run"`
+	Tasks  []shiftedTask `json:"tasks"`
+	Err    string        `json:"error"`
+}
+
+func addPause(t *testing.T, values map[string]any) string {
+	ret, err := postJSON("api/pause", values, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id := fmt.Sprint(ret["id"])
+	assert.NotEmpty(t, id)
+	return id
+}
+
+func shiftPause(t *testing.T, id string, dryRun bool) shiftResp {
+	body, err := requestJSON(fmt.Sprintf("api/pause/shift?id=%s&dry_run=%t", id, dryRun), nil, http.MethodPost)
+	assert.NoError(t, err)
+	var resp shiftResp
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Empty(t, resp.Err)
+	return resp
+}
+
+func TestPause(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+	taskDate := func(id string) string {
+		var task Task
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		return task.Date
+	}
+
+	// Отпуск на три дня, общий для всех задач
+	pauseID := addPause(t, map[string]any{"from": day(3), "to": day(5)})
+	defer postJSON("api/pause?id="+pauseID, nil, http.MethodDelete)
+
+	daily := addTask(t, task{date: day(1), title: "Зарядка", repeat: "d 1"})
+	once := addTask(t, task{date: day(4), title: "Позвонить в банк"})
+	other := addTask(t, task{date: day(3), title: "Полить цветы", repeat: "d 2"})
+
+	// Выполнение перепрыгивает дни отпуска
+	ret, err := postJSON("api/task/done?id="+daily, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, day(2), taskDate(daily))
+	ret, err = postJSON("api/task/done?id="+daily, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, day(6), taskDate(daily))
+
+	// nextdate считает правило без задачи, паузы к нему не относятся
+	body, err := requestJSON(fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=d+1", day(2), day(2)), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, day(3), string(body))
+
+	// Задачу, чьё правило больше не разбирается, сдвиг не трогает, а только сообщает о ней
+	res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, '', ?)`,
+		day(4), "Старое правило", "ooops 1")
+	assert.NoError(t, err)
+	brokenID, err := res.LastInsertId()
+	assert.NoError(t, err)
+	broken := fmt.Sprint(brokenID)
+
+	// Предпросмотр ничего не меняет
+	preview := shiftPause(t, pauseID, true)
+	assert.True(t, preview.DryRun)
+	moved := map[string]string{}
+	skipped := map[string]string{}
+	for _, task := range preview.Tasks {
+		moved[task.ID] = task.NewDate
+		skipped[task.ID] = task.Skipped
+	}
+	assert.Equal(t, day(6), moved[once])
+	assert.Empty(t, moved[broken])
+	assert.NotEmpty(t, skipped[broken])
+	assert.Empty(t, skipped[once])
+	assert.Equal(t, day(7), moved[other])
+	assert.Equal(t, day(4), taskDate(once))
+	assert.Equal(t, day(3), taskDate(other))
+
+	shifted := shiftPause(t, pauseID, false)
+	assert.False(t, shifted.DryRun)
+	assert.Equal(t, day(6), taskDate(once))
+	assert.Equal(t, day(7), taskDate(other))
+	assert.Equal(t, day(4), taskDate(broken))
+
+	// Пауза одной задачи не трогает остальные
+	otherID, err := strconv.Atoi(other)
+	assert.NoError(t, err)
+	own := addPause(t, map[string]any{"task_id": otherID, "from": day(8), "to": day(9)})
+	ret, err = postJSON("api/task/done?id="+other, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, day(11), taskDate(other))
+	ret, err = postJSON("api/task/done?id="+daily, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, day(7), taskDate(daily))
+
+	ret, err = postJSON("api/pause?id="+own, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	// Конец раньше начала и несуществующая задача
+	for _, values := range []map[string]any{
+		{"from": day(5), "to": day(3)},
+		{"from": "ooops", "to": day(3)},
+		{"task_id": 999999999, "from": day(3), "to": day(5)},
+	} {
+		ret, err = postJSON("api/pause", values, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"])
+	}
+
+	for _, id := range []string{daily, once, other, broken} {
+		ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+}
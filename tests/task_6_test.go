@@ -130,3 +130,53 @@ func TestEditTask(t *testing.T) {
 		"repeat":  "d 7",
 	})
 }
+
+func TestTaskRepeats(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+
+	// Несколько правил списком хранятся одной строкой через |
+	ret, err := postJSON("api/task", map[string]any{
+		"date":    now,
+		"title":   "Полить цветы",
+		"repeats": []string{"w 1", "m -1"},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+	assert.NotEmpty(t, ret["id"], "Ожидается id задачи, получено %v", ret["error"])
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "w 1 | m -1", task.Repeat)
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "w 1 | m -1", m["repeat"])
+	assert.Equal(t, []any{"w 1", "m -1"}, m["repeats"])
+
+	for _, v := range []map[string]any{
+		{"id": id, "date": now, "title": "Полить цветы", "repeats": []string{"w 1", "sr"}},
+		{"id": id, "date": now, "title": "Полить цветы", "repeats": []string{"w 1", ""}},
+		{"id": id, "date": now, "title": "Полить цветы", "repeat": "w 2", "repeats": []string{"w 1", "m -1"}},
+	} {
+		m, err := postJSON("api/task", v, http.MethodPut)
+		assert.NoError(t, err)
+		e, ok := m["error"]
+		assert.True(t, ok && len(fmt.Sprint(e)) > 0, "Ожидается ошибка для правил %v", v["repeats"])
+	}
+
+	m, err = postJSON("api/task", map[string]any{
+		"id":      id,
+		"date":    now,
+		"title":   "Полить цветы",
+		"repeat":  "w 1|m -1",
+		"repeats": []string{"w 1", "m -1"},
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, m["error"])
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
@@ -73,3 +74,201 @@ func TestDelTask(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, ret)
 }
+
+func TestDoneSeries(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Три раза полить цветы",
+		repeat: "d 2 count 3",
+	})
+
+	// Первые два выполнения переносят задачу, третье её удаляет
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		now = now.AddDate(0, 0, 2)
+		assert.Equal(t, task.Date, now.Format(`20060102`))
+	}
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
+
+func TestSkip(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Планёрка",
+		repeat: "d 2",
+	})
+
+	checkDate := func(want time.Time) {
+		var task Task
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, want.Format(`20060102`), task.Date)
+	}
+
+	// Пропуск текущего повторения сразу переносит задачу
+	ret, err := postJSON("api/task/skip?id="+id+"&date="+now.Format(`20060102`), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	checkDate(now.AddDate(0, 0, 2))
+
+	// Пропуск будущего повторения учитывается при выполнении
+	ret, err = postJSON("api/task/skip?id="+id+"&date="+now.AddDate(0, 0, 4).Format(`20060102`), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	checkDate(now.AddDate(0, 0, 2))
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	checkDate(now.AddDate(0, 0, 6))
+
+	ret, err = postJSON("api/task/skip?id="+id+"&date=ooops", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret)
+
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+}
+
+func TestDoneTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// Часовое правило двигает и время, и при необходимости дату
+	start := time.Now().Add(time.Hour).Truncate(time.Minute)
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   start.Format(`20060102`),
+		"time":   start.Format(`15:04`),
+		"title":  "Проветрить комнату",
+		"repeat": "h 6",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	next := start.Add(6 * time.Hour)
+	assert.Equal(t, next.Format(`20060102`), task.Date)
+	assert.Equal(t, next.Format(`15:04`), task.Time)
+
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"title": "Неправильное время",
+		"time":  "25:61",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestDoneAfter(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// Задача на будущее, но выполнили её сегодня: следующая дата от сегодня
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.AddDate(0, 0, 10).Format(`20060102`),
+		title:  "Полить цветы",
+		repeat: "d 3 after count 2",
+	})
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), task.Date)
+
+	// Второе выполнение последнее в серии
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
+
+func TestDoneSpaced(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Повторить слова",
+		repeat: "sr",
+	})
+
+	// Каждое выполнение отодвигает следующее, оценка меняет интервал
+	for _, v := range []struct {
+		grade string
+		days  int
+	}{
+		{"", 1},
+		{"good", 3},
+		{"easy", 9},
+		{"hard", 11},
+		{"again", 1},
+		{"good", 3},
+	} {
+		urlPath := "api/task/done?id=" + id
+		if v.grade != "" {
+			urlPath += "&grade=" + v.grade
+		}
+		ret, err := postJSON(urlPath, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, now.AddDate(0, 0, v.days).Format(`20060102`), task.Date, v.grade)
+	}
+
+	// Неизвестная оценка - ошибка
+	ret, err := postJSON("api/task/done?id="+id+"&grade=perfect", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	// У обычного повторения оценки не бывает
+	other := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Полить цветы",
+		repeat: "d 3",
+	})
+	ret, err = postJSON("api/task/done?id="+other+"&grade=easy", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	for _, id := range []string{id, other} {
+		_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,184 @@
+// Правила повторения прямо в браузере: nextdate.wasm - пакет nextdate, собранный в WebAssembly (cmd/wasm).
+// После загрузки модуль добавляет в этот объект nextdate.next(now, date, repeat), nextdate.validate(repeat)
+// и nextdate.describe(repeat, lang), каждая возвращает объект с результатом или с полем error,
+// как ответы /api/nextdate и /api/repeat/describe.
+// nextdate.ready разрешается true, когда функции готовы, и false, если модуль не собран или не загрузился -
+// тогда форма по-прежнему проверяет правило через сервер.
+window.nextdate = {
+    ready: (async function () {
+        if (typeof Go === "undefined" || typeof WebAssembly === "undefined") {
+            return false;
+        }
+        try {
+            const go = new Go();
+            const result = await WebAssembly.instantiateStreaming(fetch("/nextdate.wasm"), go.importObject);
+            // run не завершается, пока модуль работает, ждать его не нужно
+            go.run(result.instance);
+            return true;
+        } catch (err) {
+            console.warn("nextdate.wasm не загружен, правила проверяются на сервере:", err);
+            return false;
+        }
+    })(),
+};
+
+// Форма задачи из scripts.min.js собирает правило из своих полей только при отправке,
+// поэтому здесь оно собирается из тех же полей так же, как это делает сама форма.
+// Под выбором правила показывается его описание и следующая дата, а неверное правило
+// отклоняется до запроса к серверу с той же ошибкой, что вернул бы сервер.
+(function () {
+    const repeatTitle = "Правило повторения";
+
+    // field находит блок поля формы по его подписи
+    function field(root, title) {
+        for (const label of root.querySelectorAll(".form-label")) {
+            if (label.textContent.replace("*", "").trim() === title) {
+                return label.closest(".form-input");
+            }
+        }
+        return null;
+    }
+
+    // checkedIDs возвращает номера отмеченных пунктов списка, пункты нумеруются с 1
+    function checkedIDs(block) {
+        const ids = [];
+        if (block) {
+            block.querySelectorAll("input[type=checkbox]").forEach(function (box, i) {
+                if (box.checked) {
+                    ids.push(i + 1);
+                }
+            });
+        }
+        return ids;
+    }
+
+    function inputValue(block) {
+        const input = block && block.querySelector("input");
+        return input ? input.value : "";
+    }
+
+    // buildRepeat повторяет сборку правила в форме: d, w, m или y из выбранного вида повторения
+    function buildRepeat(root, select) {
+        switch (select.value) {
+        case "1": {
+            const days = parseInt(inputValue(field(root, "Каждые X дней")), 10);
+            return "d " + (days > 0 ? days : 1);
+        }
+        case "2": {
+            const weekdays = checkedIDs(field(root, "Дни недели"));
+            return weekdays.length > 0 ? "w " + weekdays.join(",") : "w";
+        }
+        case "3": {
+            const days = inputValue(field(root, "Дни месяца (через запятую)")).split(",")
+                .map(function (day) { return day.trim(); })
+                .filter(function (day) { return day >= 1 && day <= 31; });
+            if (checkedIDs(field(root, "Последний день месяца")).length > 0) {
+                days.push("-1");
+            }
+            if (checkedIDs(field(root, "Предпоследний день месяца")).length > 0) {
+                days.push("-2");
+            }
+            const months = checkedIDs(field(root, "Месяцы"));
+            let repeat = "m " + (days.length > 0 ? days.join(",") : "x");
+            if (months.length > 0 && months.length !== 12) {
+                repeat += " " + months.join(",");
+            }
+            return repeat;
+        }
+        case "4":
+            return "y";
+        }
+        return "";
+    }
+
+    // Даты в форме ДД.ММ.ГГГГ, в правилах ГГГГММДД
+    function formDate(value) {
+        const parts = /^(\d{2})\.(\d{2})\.(\d{4})$/.exec(value.trim());
+        return parts ? parts[3] + parts[2] + parts[1] : "";
+    }
+
+    function today() {
+        const now = new Date();
+        return String(now.getFullYear()) + String(now.getMonth() + 1).padStart(2, "0") + String(now.getDate()).padStart(2, "0");
+    }
+
+    // hintText описание правила и следующая дата или ошибка разбора
+    function hintText(root, repeat) {
+        const checked = window.nextdate.validate(repeat);
+        if (checked.error) {
+            return {text: checked.error, error: true};
+        }
+        const parts = [];
+        const described = window.nextdate.describe(repeat, "ru");
+        if (described.description) {
+            parts.push(described.description);
+        }
+        const now = today();
+        const next = window.nextdate.next(now, formDate(inputValue(field(root, "Дата"))) || now, repeat);
+        if (next.date) {
+            parts.push("дальше " + next.date.slice(6) + "." + next.date.slice(4, 6) + "." + next.date.slice(0, 4));
+        }
+        return {text: parts.join(", "), error: false};
+    }
+
+    // update пересчитывает подсказку под выбором правила, если форма открыта.
+    // Текст меняется только при изменении, иначе наблюдатель за страницей вызывал бы update по кругу.
+    function update() {
+        const block = field(document, repeatTitle);
+        const select = block && block.querySelector("select");
+        if (!select) {
+            return;
+        }
+        // Дата и поля вида правила лежат в разных колонках окна задачи
+        const root = block.closest("[slot=content]") || document;
+        let hint = block.querySelector(".repeat-hint");
+        if (!hint) {
+            hint = document.createElement("div");
+            hint.className = "repeat-hint";
+            hint.style.fontSize = "0.85em";
+            hint.style.marginTop = "0.25em";
+            block.appendChild(hint);
+        }
+
+        const repeat = buildRepeat(root, select);
+        const result = repeat ? hintText(root, repeat) : {text: "", error: false};
+        const color = result.error ? "#c0392b" : "";
+        if (hint.textContent !== result.text) {
+            hint.textContent = result.text;
+        }
+        if (hint.style.color !== color) {
+            hint.style.color = color;
+        }
+    }
+
+    // Неверное правило не отправляем, форма покажет ошибку так же, как ответ сервера
+    function checkRequest(config) {
+        const method = (config.method || "").toLowerCase();
+        const data = config.data;
+        if ((method !== "post" && method !== "put") || !/(^|\/)api\/task$/.test(config.url) ||
+            !data || typeof data !== "object" || !data.repeat) {
+            return config;
+        }
+        const checked = window.nextdate.validate(data.repeat);
+        if (checked.error) {
+            config.adapter = function () {
+                return Promise.resolve({data: {error: checked.error}, status: 400, statusText: "Bad Request", headers: {}, config: config});
+            };
+        }
+        return config;
+    }
+
+    window.nextdate.ready.then(function (ready) {
+        if (!ready) {
+            return;
+        }
+        if (typeof axios !== "undefined") {
+            axios.interceptors.request.use(checkRequest);
+        }
+        document.addEventListener("input", update, true);
+        document.addEventListener("change", update, true);
+        // Форма открывается и меняет поля под видом правила без событий ввода
+        new MutationObserver(update).observe(document.body, {childList: true, subtree: true});
+        update();
+    });
+})();